@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/migrations"
+	"github.com/spf13/cobra"
+)
+
+// apiKeyFlags contains flags of the apikey subcommands.
+var apiKeyFlags struct {
+	name    string
+	scopes  []string
+	domains []string
+}
+
+// APIKeyCmd manages keys of the public API clients.
+var APIKeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage public API keys",
+	Long: `Manage keys clients authenticate to the public API with, keys are required if public_api.auth.enabled is set.
+Only hashes of keys are stored, a key is shown once when it's created.`,
+}
+
+var apiKeyCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a new API key",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if err := withAPIKeyRepo(func(repo *db.APIKeyRepo) error {
+			k, key, err := repo.CreateAPIKey(context.Background(), &db.CreateAPIKeyOpts{
+				Name:    apiKeyFlags.name,
+				Scopes:  apiKeyFlags.scopes,
+				Domains: apiKeyFlags.domains,
+			})
+			if err != nil {
+				return err
+			}
+
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "id:  %s\nkey: %s\n\nThe key is shown only once, store it securely.\n", k.ID, key)
+
+			return nil
+		}); err != nil {
+			exitWithErr(err)
+		}
+	},
+}
+
+var apiKeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API keys",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if err := withAPIKeyRepo(func(repo *db.APIKeyRepo) error {
+			keys, err := repo.ListAPIKeys(context.Background())
+			if err != nil {
+				return err
+			}
+
+			printAPIKeys(cmd.OutOrStdout(), keys)
+
+			return nil
+		}); err != nil {
+			exitWithErr(err)
+		}
+	},
+}
+
+var apiKeyRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke an API key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := withAPIKeyRepo(func(repo *db.APIKeyRepo) error {
+			if err := repo.RevokeAPIKey(context.Background(), args[0]); err != nil {
+				if errors.Is(err, db.ErrAPIKeyNotFound) {
+					return fmt.Errorf("active API key '%s' not found", args[0])
+				}
+
+				return err
+			}
+
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "revoked %s\n", args[0])
+
+			return nil
+		}); err != nil {
+			exitWithErr(err)
+		}
+	},
+}
+
+func init() {
+	apiKeyCreateCmd.Flags().StringVar(&apiKeyFlags.name, "name", "",
+		"name of the key owner, it's shown in access logs")
+	apiKeyCreateCmd.Flags().StringSliceVar(&apiKeyFlags.scopes, "scopes", []string{db.ScopeRead},
+		"scopes granted to the key: "+strings.Join(db.Scopes, ", "))
+	apiKeyCreateCmd.Flags().StringSliceVar(&apiKeyFlags.domains, "domains", nil,
+		"domains the key has access to, all domains if omitted")
+	_ = apiKeyCreateCmd.MarkFlagRequired("name")
+
+	APIKeyCmd.AddCommand(apiKeyCreateCmd, apiKeyListCmd, apiKeyRevokeCmd)
+	RootCmd.AddCommand(APIKeyCmd)
+}
+
+// withAPIKeyRepo calls fn with a repository of API keys of the configured database,
+// the database is closed before an error is returned.
+func withAPIKeyRepo(fn func(repo *db.APIKeyRepo) error) error {
+	logger, err := initConfigAndLogger()
+	if err != nil {
+		return err
+	}
+
+	b, err := backend.New(logger)
+	if err != nil {
+		return fmt.Errorf("failed to init backend: %w", err)
+	}
+	defer b.Shutdown()
+
+	if err := migrations.NewMigrator(logger, b.DB).Ensure(context.Background(), config.Config.DB.AutoMigrate); err != nil {
+		return err
+	}
+
+	return fn(db.NewAPIKeyRepo(logger, b.DB))
+}
+
+func printAPIKeys(w io.Writer, keys []*db.APIKey) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tDOMAINS\tCREATED\tREVOKED")
+	for _, k := range keys {
+		domains := "all"
+		if !k.AllDomains() {
+			domains = strings.Join(k.Domains, ",")
+		}
+		revoked := "-"
+		if !k.Revoked.IsZero() {
+			revoked = k.Revoked.Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.ID, k.Name, strings.Join(k.Scopes, ","), domains, k.Created.Format(time.RFC3339), revoked)
+	}
+	_ = tw.Flush()
+}
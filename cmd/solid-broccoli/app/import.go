@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/importer"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/migrations"
+	"github.com/spf13/cobra"
+)
+
+const stdinPath = "-"
+
+// importFlags contains flags of the import command.
+var importFlags struct {
+	format    string
+	profile   string
+	mappings  []string
+	domain    string
+	batchSize int
+	dryRun    bool
+}
+
+// ImportCmd loads positions from files into the configured database.
+var ImportCmd = &cobra.Command{
+	Use:   "import [file...]",
+	Short: "Import positions from CSV, TSV or NDJSON files",
+	Long: `Import positions from CSV, TSV or NDJSON files into the configured database.
+Standard input is read if no files are given or the file is "-".
+
+Columns of the input are mapped to position fields with a profile, available profiles: ` +
+		strings.Join(importer.Profiles(), ", ") + `.
+Any field of the profile can be remapped with --map field=column.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runImport(cmd, args); err != nil {
+			exitWithErr(err)
+		}
+	},
+}
+
+func init() {
+	ImportCmd.Flags().StringVar(&importFlags.format, "format", "",
+		"input format: csv, tsv or ndjson, detected by the file extension if omitted, csv for standard input")
+	ImportCmd.Flags().StringVar(&importFlags.profile, "profile", importer.DefaultProfile,
+		"columns mapping profile")
+	ImportCmd.Flags().StringArrayVar(&importFlags.mappings, "map", nil,
+		"remap a field to a column of the input, e.g. --map position=Rank")
+	ImportCmd.Flags().StringVar(&importFlags.domain, "domain", "",
+		"domain of positions without domain column")
+	ImportCmd.Flags().IntVar(&importFlags.batchSize, "batch-size", importer.DefaultBatchSize,
+		"number of positions written within a single transaction")
+	ImportCmd.Flags().BoolVar(&importFlags.dryRun, "dry-run", false,
+		"report what would be written without writing anything")
+
+	RootCmd.AddCommand(ImportCmd)
+}
+
+// runImport imports the files, the database is closed before an error is returned.
+func runImport(cmd *cobra.Command, args []string) error {
+	logger, err := initConfigAndLogger()
+	if err != nil {
+		return err
+	}
+
+	profile, err := importer.GetProfile(importFlags.profile)
+	if err != nil {
+		return err
+	}
+	if err := profile.Override(importFlags.mappings); err != nil {
+		return err
+	}
+
+	b, err := backend.New(logger)
+	if err != nil {
+		return fmt.Errorf("failed to init backend: %w", err)
+	}
+	defer b.Shutdown()
+
+	if err := migrations.NewMigrator(logger, b.DB).Ensure(context.Background(), config.Config.DB.AutoMigrate); err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		args = []string{stdinPath}
+	}
+
+	repo := db.NewPositionRepo(logger, b.DB)
+	total := &importer.Summary{}
+	for _, path := range args {
+		summary, err := importFile(cmd, repo, profile, path)
+		if summary != nil {
+			total.Add(summary)
+		}
+		if err != nil {
+			printImportSummary(cmd.OutOrStdout(), total)
+
+			return fmt.Errorf("failed to import %s: %w", path, err)
+		}
+	}
+
+	printImportSummary(cmd.OutOrStdout(), total)
+
+	return nil
+}
+
+func importFile(cmd *cobra.Command, repo db.Repository, profile *importer.Profile, path string) (*importer.Summary, error) {
+	format := importFlags.format
+
+	var input io.Reader
+	if path == stdinPath {
+		input = cmd.InOrStdin()
+		if format == "" {
+			format = importer.FormatCSV
+		}
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		input = f
+
+		if format == "" {
+			if format, err = importer.FormatFromPath(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	r, err := importer.NewReader(input, format, profile, importFlags.domain)
+	if err != nil {
+		return nil, err
+	}
+
+	return importer.Import(context.Background(), repo, r, importer.Options{
+		BatchSize: importFlags.batchSize,
+		DryRun:    importFlags.dryRun,
+		OnReject: func(record *importer.Record) {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s:%d: %s\n", path, record.Line, record.Err)
+		},
+	})
+}
+
+func printImportSummary(w io.Writer, summary *importer.Summary) {
+	if importFlags.dryRun {
+		_, _ = fmt.Fprintf(w, "dry run, nothing has been written: %d read, %d would be inserted, %d would be updated, %d rejected\n",
+			summary.Read, summary.Inserted, summary.Updated, summary.Rejected)
+
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "%d read, %d inserted, %d updated, %d rejected\n",
+		summary.Read, summary.Inserted, summary.Updated, summary.Rejected)
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/migrations"
+	"github.com/spf13/cobra"
+)
+
+// migrateFlags contains flags of the migrate subcommands.
+var migrateFlags struct {
+	to    int
+	steps int
+}
+
+// MigrateCmd manages migrations of the configured database.
+var MigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manage DB schema migrations",
+	Long: `Manage DB schema migrations of the configured database.
+The service refuses to start if the schema doesn't match the binary unless db.auto_migrate is set.`,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply pending migrations",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runMigrator(cmd, "applied", func(ctx context.Context, m *migrations.Migrator) ([]*migrations.Migration, error) {
+			return m.Up(ctx, migrateFlags.to)
+		})
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Revert the latest applied migrations",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		runMigrator(cmd, "reverted", func(ctx context.Context, m *migrations.Migrator) ([]*migrations.Migration, error) {
+			return m.Down(ctx, migrateFlags.steps)
+		})
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show applied and pending migrations",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if err := withMigrator(func(m *migrations.Migrator) error {
+			statuses, err := m.Status(context.Background())
+			if err != nil {
+				return err
+			}
+
+			printMigrationsStatus(cmd.OutOrStdout(), statuses)
+
+			return nil
+		}); err != nil {
+			exitWithErr(err)
+		}
+	},
+}
+
+func init() {
+	migrateUpCmd.Flags().IntVar(&migrateFlags.to, "to", 0,
+		"version to migrate up to, the latest one if omitted")
+	migrateDownCmd.Flags().IntVar(&migrateFlags.steps, "steps", 1,
+		"number of migrations to revert")
+
+	MigrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd)
+	RootCmd.AddCommand(MigrateCmd)
+}
+
+// withMigrator calls fn with a migrator of the configured database, the database is closed
+// before an error is returned.
+func withMigrator(fn func(m *migrations.Migrator) error) error {
+	logger, err := initConfigAndLogger()
+	if err != nil {
+		return err
+	}
+
+	b, err := backend.New(logger)
+	if err != nil {
+		return fmt.Errorf("failed to init backend: %w", err)
+	}
+	defer b.Shutdown()
+
+	return fn(migrations.NewMigrator(logger, b.DB))
+}
+
+func runMigrator(cmd *cobra.Command, action string,
+	fn func(ctx context.Context, m *migrations.Migrator) ([]*migrations.Migration, error)) {
+	if err := withMigrator(func(m *migrations.Migrator) error {
+		done, err := fn(context.Background(), m)
+		for _, migration := range done {
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s %d: %s\n", action, migration.Version, migration.Description)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), "nothing to do")
+		}
+
+		return nil
+	}); err != nil {
+		exitWithErr(err)
+	}
+}
+
+func printMigrationsStatus(w io.Writer, statuses []*migrations.Status) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "VERSION\tDESCRIPTION\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\n", status.Version, status.Description, appliedAt)
+	}
+	_ = tw.Flush()
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
 	"github.com/dstdfx/solid-broccoli/internal/pkg/log"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 const defaultCfgFile = "/etc/solid-broccoli/solid-broccoli.yaml"
@@ -29,19 +30,7 @@ var RootCmd = &cobra.Command{
 	Short: "solid-broccoli represents a simple HTTP API service",
 	Run: func(_ *cobra.Command, _ []string) {
 		// Initialize application config and log.
-		if _, err := os.Stat(cfgFile); err != nil {
-			exitWithErr(fmt.Errorf("config file %s can't be read: %s", cfgFile, err))
-		}
-		if err := config.InitFromFile(cfgFile); err != nil {
-			exitWithErr(err)
-		}
-
-		// Init logger
-		logger, err := log.InitLogger(log.InitLoggerOpts{
-			File:      config.Config.Log.File,
-			UseStdout: config.Config.Log.UseStdout,
-			Debug:     config.Config.Log.Debug,
-		})
+		logger, err := initConfigAndLogger()
 		if err != nil {
 			exitWithErr(err)
 		}
@@ -66,6 +55,23 @@ func init() {
 		defaultCfgFile, "path to application config")
 }
 
+// initConfigAndLogger initializes global application config and returns a logger configured with it.
+func initConfigAndLogger() (*zap.Logger, error) {
+	if _, err := os.Stat(cfgFile); err != nil {
+		return nil, fmt.Errorf("config file %s can't be read: %s", cfgFile, err)
+	}
+	if err := config.InitFromFile(cfgFile); err != nil {
+		return nil, err
+	}
+
+	// Init logger
+	return log.InitLogger(log.InitLoggerOpts{
+		File:      config.Config.Log.File,
+		UseStdout: config.Config.Log.UseStdout,
+		Debug:     config.Config.Log.Debug,
+	})
+}
+
 // exitWithErr is a helper method to print errors in case of empty logger.
 func exitWithErr(err error) {
 	_, _ = fmt.Fprintf(os.Stderr, "application is exiting after error: %s\n", err)
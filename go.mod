@@ -3,9 +3,11 @@ module github.com/dstdfx/solid-broccoli
 go 1.15
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/go-chi/chi v4.1.2+incompatible
 	github.com/gofrs/uuid v3.3.0+incompatible
 	github.com/jmoiron/sqlx v1.2.0
+	github.com/lib/pq v1.9.0
 	github.com/mattn/go-sqlite3 v1.14.2
 	github.com/prometheus/client_golang v0.9.3
 	github.com/spf13/cobra v1.0.0
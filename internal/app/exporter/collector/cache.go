@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"github.com/dstdfx/solid-broccoli/internal/pkg/cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cacheCollector reports usage counters of the cache of DB query results.
+type cacheCollector struct {
+	cache *cache.LRU
+
+	hits      *prometheus.Desc
+	misses    *prometheus.Desc
+	evictions *prometheus.Desc
+	entries   *prometheus.Desc
+}
+
+// NewCacheCollector is a collector with cache hits, misses, evictions and a number of entries.
+func NewCacheCollector(c *cache.LRU) prometheus.Collector {
+	return &cacheCollector{
+		cache:     c,
+		hits:      prometheus.NewDesc("cache_hits_total", "Number of query results served from the cache.", nil, nil),
+		misses:    prometheus.NewDesc("cache_misses_total", "Number of query results missing in the cache.", nil, nil),
+		evictions: prometheus.NewDesc("cache_evictions_total", "Number of query results evicted from the full cache.", nil, nil),
+		entries:   prometheus.NewDesc("cache_entries", "Number of query results in the cache.", nil, nil),
+	}
+}
+
+// Describe implements Collector.
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.evictions
+	ch <- c.entries
+}
+
+// Collect implements Collector.
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.cache.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(stats.Entries))
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/cache"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheCollector(t *testing.T) {
+	// Prepare expected Prometheus metrics.
+	expected := []*regexp.Regexp{
+		regexp.MustCompile(`cache_hits_total 2`),
+		regexp.MustCompile(`cache_misses_total 1`),
+		regexp.MustCompile(`cache_evictions_total 1`),
+		regexp.MustCompile(`cache_entries 1`),
+	}
+
+	c := cache.New(1, time.Minute)
+	c.Set("a", 1)
+	c.Get("a")
+	c.Get("a")
+	c.Set("b", 2)
+	c.Get("a")
+
+	// Register NewCacheCollector and run Prometheus server.
+	collector := NewCacheCollector(c)
+	prometheusEnv, err := testutils.SetupPrometheus(collector)
+	assert.NoError(t, err)
+	defer prometheusEnv.TearDown(collector)
+
+	// Retrieve data and compare it with the expected metrics.
+	testutils.HandlePrometheusMetric(t, &testutils.HandlePrometheusMetricOpts{
+		Env:      prometheusEnv,
+		Expected: expected,
+	})
+}
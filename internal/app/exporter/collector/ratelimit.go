@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"github.com/dstdfx/solid-broccoli/internal/pkg/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rateLimitCollector reports requests rejected by the rate limiter of the public API.
+type rateLimitCollector struct {
+	limiter *ratelimit.Limiter
+
+	throttled *prometheus.Desc
+}
+
+// NewRateLimitCollector is a collector with numbers of rate limited requests per route.
+func NewRateLimitCollector(l *ratelimit.Limiter) prometheus.Collector {
+	return &rateLimitCollector{
+		limiter: l,
+		throttled: prometheus.NewDesc("rate_limited_requests_total",
+			"Number of requests rejected with 429 Too Many Requests.", []string{"route"}, nil),
+	}
+}
+
+// Describe implements Collector.
+func (c *rateLimitCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.throttled
+}
+
+// Collect implements Collector.
+func (c *rateLimitCollector) Collect(ch chan<- prometheus.Metric) {
+	for route, n := range c.limiter.Throttled() {
+		ch <- prometheus.MustNewConstMetric(c.throttled, prometheus.CounterValue, float64(n), route)
+	}
+}
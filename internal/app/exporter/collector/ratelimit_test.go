@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/ratelimit"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitCollector(t *testing.T) {
+	// Prepare expected Prometheus metrics.
+	expected := []*regexp.Regexp{
+		regexp.MustCompile(`rate_limited_requests_total{route="positions"} 2`),
+		regexp.MustCompile(`rate_limited_requests_total{route="export"} 1`),
+	}
+
+	l := ratelimit.New(ratelimit.Limit{Rate: 0.001, Burst: 1}, nil)
+	for i := 0; i < 3; i++ {
+		l.Allow("positions", "127.0.0.1")
+	}
+	l.Allow("export", "127.0.0.1")
+	l.Allow("export", "127.0.0.1")
+
+	// Register NewRateLimitCollector and run Prometheus server.
+	collector := NewRateLimitCollector(l)
+	prometheusEnv, err := testutils.SetupPrometheus(collector)
+	assert.NoError(t, err)
+	defer prometheusEnv.TearDown(collector)
+
+	// Retrieve data and compare it with the expected metrics.
+	testutils.HandlePrometheusMetric(t, &testutils.HandlePrometheusMetricOpts{
+		Env:      prometheusEnv,
+		Expected: expected,
+	})
+}
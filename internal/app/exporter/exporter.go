@@ -4,6 +4,8 @@ import (
 	"sync"
 
 	"github.com/dstdfx/solid-broccoli/internal/app/exporter/collector"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/cache"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/ratelimit"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -21,19 +23,32 @@ type NewAPIExporterOpts struct {
 	BuildGitTag    string
 	BuildDate      string
 	BuildCompiler  string
+
+	// Cache is reported if it's set.
+	Cache *cache.LRU
+	// RateLimiter is reported if it's set.
+	RateLimiter *ratelimit.Limiter
 }
 
 // NewAPIExporter returns a reference to a new instance of APIExporter.
 func NewAPIExporter(opts *NewAPIExporterOpts) *APIExporter {
+	collectors := []prometheus.Collector{
+		collector.NewBuildInfoCollector(&collector.NewBuildInfoCollectorOpts{
+			BuildGitCommit: opts.BuildGitCommit,
+			BuildGitTag:    opts.BuildGitTag,
+			BuildDate:      opts.BuildDate,
+			BuildCompiler:  opts.BuildCompiler,
+		}),
+	}
+	if opts.Cache != nil {
+		collectors = append(collectors, collector.NewCacheCollector(opts.Cache))
+	}
+	if opts.RateLimiter != nil {
+		collectors = append(collectors, collector.NewRateLimitCollector(opts.RateLimiter))
+	}
+
 	return &APIExporter{
-		collectors: []prometheus.Collector{
-			collector.NewBuildInfoCollector(&collector.NewBuildInfoCollectorOpts{
-				BuildGitCommit: opts.BuildGitCommit,
-				BuildGitTag:    opts.BuildGitTag,
-				BuildDate:      opts.BuildDate,
-				BuildCompiler:  opts.BuildCompiler,
-			}),
-		},
+		collectors: collectors,
 	}
 }
 
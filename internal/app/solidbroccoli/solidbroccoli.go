@@ -15,7 +15,10 @@ import (
 	"github.com/dstdfx/solid-broccoli/internal/app/exporter"
 	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
 	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
 	public "github.com/dstdfx/solid-broccoli/internal/pkg/http"
+	v1 "github.com/dstdfx/solid-broccoli/internal/pkg/http/v1"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/migrations"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
@@ -57,12 +60,37 @@ func StartService(log *zap.Logger, opts StartOpts) error {
 	}
 	defer b.Shutdown()
 
+	if err := migrations.NewMigrator(log, b.DB).Ensure(context.Background(), config.Config.DB.AutoMigrate); err != nil {
+		return fmt.Errorf("failed to check DB schema: %w", err)
+	}
+
+	// Other processes can't write to in-memory database
+	if b.Cache != nil && config.Config.DB.Driver != config.DBDriverMemory {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		if err := db.WatchVersion(ctx, log, db.NewPositionRepo(log, b.DB), b.Cache,
+			time.Duration(config.Config.Cache.PollInterval)*time.Second); err != nil {
+			return fmt.Errorf("failed to watch dataset version: %w", err)
+		}
+	}
+
+	// Cold cache only makes first requests slower
+	if b.Cache != nil {
+		repo := db.NewCachedRepo(db.NewPositionRepo(log, b.DB), b.Cache)
+		if err := repo.WarmUp(context.Background(), config.Config.Cache.WarmUp, v1.DefaultLimit()); err != nil {
+			log.Warn("failed to warm up cache", zap.Error(err))
+		}
+	}
+
 	// Register new Prometheus exporter
 	if err := prometheus.Register(exporter.NewAPIExporter(&exporter.NewAPIExporterOpts{
 		BuildGitCommit: opts.BuildGitCommit,
 		BuildGitTag:    opts.BuildGitTag,
 		BuildDate:      opts.BuildDate,
 		BuildCompiler:  opts.BuildCompiler,
+		Cache:          b.Cache,
+		RateLimiter:    b.RateLimiter,
 	})); err != nil {
 		return fmt.Errorf("failed to register prometheus exporter: %w", err)
 	}
@@ -102,6 +130,8 @@ func StartService(log *zap.Logger, opts StartOpts) error {
 		WriteTimeout: time.Duration(config.Config.PublicAPI.WriteTimeout) * time.Second,
 		IdleTimeout:  time.Duration(config.Config.PublicAPI.IdleTimeout) * time.Second,
 		Handler:      public.InitAPIRouter(log, b),
+		// Export extends the write timeout with the connection saved into the context
+		ConnContext: v1.ConnContext,
 	}
 
 	log.Debug("wait for shutdown signals")
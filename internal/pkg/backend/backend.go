@@ -1,40 +1,126 @@
 package backend
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"sync/atomic"
+	"time"
 
+	"github.com/dstdfx/solid-broccoli/internal/pkg/cache"
 	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/ratelimit"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 
+	_ "github.com/lib/pq"           // postgres driver import
 	_ "github.com/mattn/go-sqlite3" // sqlite3 driver import
 )
 
+// memoryDSN names an in-memory SQLite database shared by connections of the pool, it exists
+// as long as any of them is open.
+const memoryDSN = "file:memory-%d?mode=memory&cache=shared"
+
+// memoryDBs numbers in-memory databases, so that every backend gets its own one.
+var memoryDBs int64
+
 // Backend contains application connections to different external services and additional
 // parameters that should be passed to API middlewares.
 type Backend struct {
 	Log *zap.Logger
 	DB  *sqlx.DB
+	// Cache contains query results of the DB, it's nil if caching is disabled.
+	Cache *cache.LRU
+	// RateLimiter keeps request limits of public API clients, it's nil if rate limiting is disabled.
+	RateLimiter *ratelimit.Limiter
+
+	// pinned keeps an in-memory database alive while the pool opens and closes other connections.
+	pinned        *sql.Conn
+	shutdownHooks []func()
 }
 
 // New init new Backend instance.
 func New(log *zap.Logger) (*Backend, error) {
 	// Init DB connection
-	conn, err := sqlx.Connect("sqlite3", config.Config.DB.DSN)
+	conn, pinned, err := connectDB(config.Config.DB)
 	if err != nil {
 		return nil, fmt.Errorf("failed to init DB connection: %w", err)
 	}
 
-	return &Backend{
-		Log: log,
-		DB:  conn,
-	}, nil
+	b := &Backend{
+		Log:    log,
+		DB:     conn,
+		pinned: pinned,
+	}
+
+	if cfg := config.Config.Cache; cfg.Enabled {
+		b.Cache = cache.New(cfg.Size, time.Duration(cfg.TTL)*time.Second)
+	}
+
+	if cfg := config.Config.PublicAPI.RateLimit; cfg.Enabled {
+		b.RateLimiter = newRateLimiter(cfg)
+	}
+
+	return b, nil
+}
+
+func newRateLimiter(cfg config.RateLimitConfig) *ratelimit.Limiter {
+	limits := make(map[string]ratelimit.Limit, len(cfg.Routes))
+	for route, limit := range cfg.Routes {
+		limits[route] = ratelimit.Limit{Rate: limit.Rate, Burst: limit.Burst}
+	}
+
+	return ratelimit.New(ratelimit.Limit{Rate: cfg.Default.Rate, Burst: cfg.Default.Burst}, limits)
+}
+
+// connectDB opens a connection to the DB of the configured driver, SQLite is used by default.
+// A connection pinned for the in-memory database is returned along with it.
+func connectDB(cfg config.DBConfig) (*sqlx.DB, *sql.Conn, error) {
+	switch cfg.Driver {
+	case config.DBDriverPostgres:
+		conn, err := sqlx.Connect("postgres", cfg.DSN)
+
+		return conn, nil, err
+	case config.DBDriverMemory:
+		conn, err := sqlx.Connect("sqlite3", fmt.Sprintf(memoryDSN, atomic.AddInt64(&memoryDBs, 1)))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// The database is gone once the last connection is closed, e.g. after a bad connection is dropped
+		pinned, err := conn.Conn(context.Background())
+		if err != nil {
+			_ = conn.Close()
+
+			return nil, nil, err
+		}
+
+		return conn, pinned, nil
+	default:
+		conn, err := sqlx.Connect("sqlite3", cfg.DSN)
+
+		return conn, nil, err
+	}
+}
+
+// OnShutdown registers a function called by Shutdown before connections are closed, e.g. to
+// store data buffered in memory. Functions are called in reverse order.
+func (b *Backend) OnShutdown(fn func()) {
+	b.shutdownHooks = append(b.shutdownHooks, fn)
 }
 
 // Shutdown method closes all backend connections.
 func (b *Backend) Shutdown() {
 	b.Log.Debug("backend shutdown")
 
+	for i := len(b.shutdownHooks) - 1; i >= 0; i-- {
+		b.shutdownHooks[i]()
+	}
+
+	if b.pinned != nil {
+		_ = b.pinned.Close()
+	}
+
 	// Close DB connection
 	if b.DB != nil {
 		if err := b.DB.Close(); err != nil {
@@ -1,7 +1,9 @@
 package backend
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
 	"github.com/dstdfx/solid-broccoli/internal/pkg/log"
@@ -10,11 +12,6 @@ import (
 )
 
 func TestBackend(t *testing.T) {
-	// Check acceptance test flag
-	if !testutils.IsAccTestEnabled(t) {
-		return
-	}
-
 	// Init global app configuration
 	testutils.InitTestConfig()
 
@@ -31,3 +28,79 @@ func TestBackend(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, b)
 }
+
+func TestBackendOnShutdown(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := New(logger)
+	assert.NoError(t, err)
+
+	var calls []int
+	b.OnShutdown(func() { calls = append(calls, 1) })
+	b.OnShutdown(func() {
+		// Connections are still open
+		assert.NoError(t, b.DB.Ping())
+		calls = append(calls, 2)
+	})
+
+	b.Shutdown()
+	assert.Equal(t, []int{2, 1}, calls)
+}
+
+func TestBackendMemoryDB(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+	if config.Config.DB.Driver != config.DBDriverMemory {
+		t.Skip("in-memory database isn't used")
+	}
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := New(logger)
+	assert.NoError(t, err)
+	defer b.Shutdown()
+
+	_, err = b.DB.Exec(`CREATE TABLE items (id integer)`)
+	assert.NoError(t, err)
+	_, err = b.DB.Exec(`INSERT INTO items (id) VALUES (1), (2)`)
+	assert.NoError(t, err)
+
+	// Rows being read don't block queries of other connections
+	rows, err := b.DB.Query(`SELECT id FROM items`)
+	assert.NoError(t, err)
+	assert.True(t, rows.Next())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var count int
+	assert.NoError(t, b.DB.QueryRowContext(ctx, `SELECT COUNT(1) FROM items`).Scan(&count))
+	assert.Equal(t, 2, count)
+	assert.NoError(t, rows.Close())
+
+	// The database outlives connections of the pool
+	b.DB.SetMaxIdleConns(0)
+	assert.NoError(t, b.DB.QueryRow(`SELECT COUNT(1) FROM items`).Scan(&count))
+	assert.Equal(t, 2, count)
+
+	// Every backend has its own database
+	other, err := New(logger)
+	assert.NoError(t, err)
+	defer other.Shutdown()
+	_, err = other.DB.Exec(`SELECT COUNT(1) FROM items`)
+	assert.Error(t, err)
+}
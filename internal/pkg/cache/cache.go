@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Stats represents counters of cache usage since its creation.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// LRU is a size-bounded cache evicting least recently used entries, every entry
+// expires after TTL. It's safe for concurrent use.
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[string]*list.Element
+	order *list.List
+	stats Stats
+
+	// generation is incremented by Purge so that values loaded before it aren't stored.
+	generation uint64
+
+	now func() time.Time
+}
+
+// New returns new instance of LRU holding up to size entries.
+func New(size int, ttl time.Duration) *LRU {
+	return &LRU{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+		now:   time.Now,
+	}
+}
+
+// Get returns a value of the key if it's cached and not expired.
+func (c *LRU) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(key)
+}
+
+// Set caches the value of the key, the least recently used entry is evicted if the cache is full.
+func (c *LRU) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value)
+}
+
+// Load returns a cached value of the key or calls load and caches its result.
+// Errors aren't cached, neither are values loaded while the cache was purged.
+func (c *LRU) Load(key string, load func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if value, ok := c.get(key); ok {
+		c.mu.Unlock()
+
+		return value, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.set(key, value)
+	}
+
+	return value, nil
+}
+
+// Purge removes all entries.
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element, c.size)
+	c.order.Init()
+	c.generation++
+}
+
+// Stats returns counters of cache usage.
+func (c *LRU) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+
+	return stats
+}
+
+func (c *LRU) get(key string) (interface{}, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		c.stats.Misses++
+
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	c.stats.Hits++
+
+	return e.value, true
+}
+
+func (c *LRU) set(key string, value interface{}) {
+	expires := c.now().Add(c.ttl)
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry)
+		e.value = value
+		e.expires = expires
+		c.order.MoveToFront(el)
+
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value, expires: expires})
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(2, time.Minute)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	// "a" becomes the most recently used one
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	c.Set("c", 3)
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	v, ok = c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	v, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+
+	assert.Equal(t, Stats{Hits: 3, Misses: 1, Evictions: 1, Entries: 2}, c.Stats())
+}
+
+func TestLRUExpires(t *testing.T) {
+	now := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	c := New(10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1)
+
+	now = now.Add(59 * time.Second)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Stats().Entries)
+}
+
+func TestLRULoad(t *testing.T) {
+	c := New(10, time.Minute)
+
+	calls := 0
+	load := func() (interface{}, error) {
+		calls++
+
+		return calls, nil
+	}
+
+	v, err := c.Load("a", load)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	v, err = c.Load("a", load)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 1, calls)
+
+	// Errors aren't cached
+	_, err = c.Load("b", func() (interface{}, error) { return nil, errors.New("failed") })
+	assert.Error(t, err)
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+
+	// A value loaded concurrently with Purge may be stale
+	v, err = c.Load("c", func() (interface{}, error) {
+		c.Purge()
+
+		return "stale", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "stale", v)
+	_, ok = c.Get("c")
+	assert.False(t, ok)
+}
+
+func TestLRUPurge(t *testing.T) {
+	c := New(10, time.Minute)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Purge()
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Stats().Entries)
+}
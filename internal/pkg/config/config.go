@@ -2,8 +2,11 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
+	"strings"
 
 	yaml "gopkg.in/yaml.v2"
 )
@@ -22,9 +25,41 @@ const (
 	defaultHTTPWriteTimeout = 120
 	defaultHTTPIdleTimeout  = 240
 
+	// defaultExportWriteTimeout is an hour, exports of large domains take long.
+	defaultExportWriteTimeout = 3600
+
+	defaultPositionsPerPage    = 10
+	defaultMaxPositionsPerPage = 100
+
 	defaultSQliteDSN = "data/positions.db"
+
+	defaultCompressionMinSize     = 1024
+	defaultCompressionGzipLevel   = 6
+	defaultCompressionBrotliLevel = 5
+	maxCompressionGzipLevel       = 9
+	maxCompressionBrotliLevel     = 11
+
+	defaultRateLimitRate  = 10
+	defaultRateLimitBurst = 20
+
+	defaultUsageFlushInterval = 10
+
+	defaultCacheSize         = 1000
+	defaultCacheTTL          = 60
+	defaultCachePollInterval = 1
+)
+
+// Drivers of the positions storage.
+const (
+	DBDriverSQLite   = "sqlite"
+	DBDriverPostgres = "postgres"
+	// DBDriverMemory keeps positions in an in-memory SQLite database, they are lost on shutdown.
+	DBDriverMemory = "memory"
 )
 
+// DefaultCTRCurve contains click-through rates of the top 10 organic positions.
+var DefaultCTRCurve = []float64{0.316, 0.158, 0.1, 0.071, 0.051, 0.044, 0.03, 0.021, 0.019, 0.016}
+
 // Config is a global container for all configuration options.
 var Config *AppConfig
 
@@ -35,6 +70,8 @@ type AppConfig struct {
 	DB         DBConfig               `yaml:"db"`
 	ServiceAPI ServiceAPIServerConfig `yaml:"service_api"`
 	Sentry     SentryConfig           `yaml:"sentry"`
+	Traffic    TrafficConfig          `yaml:"traffic"`
+	Cache      CacheConfig            `yaml:"cache"`
 }
 
 // LogConfig contains logger configuration.
@@ -51,6 +88,107 @@ type PublicAPIServerConfig struct {
 	ReadTimeout   int    `yaml:"read_timeout"`
 	WriteTimeout  int    `yaml:"write_timeout"`
 	IdleTimeout   int    `yaml:"idle_timeout"`
+	// ExportWriteTimeout replaces WriteTimeout for export responses, they're streamed for as
+	// long as positions are read.
+	ExportWriteTimeout int `yaml:"export_write_timeout"`
+
+	// PositionsPerPage is a number of positions returned if client doesn't set the limit.
+	PositionsPerPage int `yaml:"positions_per_page"`
+	// MaxPositionsPerPage is the largest limit of positions a client can set.
+	MaxPositionsPerPage int `yaml:"max_positions_per_page"`
+
+	// MaxAge contains max-age of Cache-Control header in seconds per route, e.g. 'summary' or
+	// 'positions'. Responses of other routes get DefaultMaxAge, zero max-age makes clients
+	// revalidate every response.
+	MaxAge        map[string]int `yaml:"max_age"`
+	DefaultMaxAge int            `yaml:"default_max_age"`
+
+	Compression CompressionConfig `yaml:"compression"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Usage       UsageConfig       `yaml:"usage"`
+}
+
+// AuthConfig contains configuration of public API authentication.
+type AuthConfig struct {
+	// Enabled makes every request require an API key created by 'apikey create' command.
+	Enabled bool `yaml:"enabled"`
+}
+
+// CompressionConfig contains parameters of public API responses compression.
+type CompressionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MinSize is a number of bytes a response has to reach to be compressed.
+	MinSize int `yaml:"min_size"`
+	// GzipLevel is between 1 (best speed) and 9 (best compression).
+	GzipLevel int `yaml:"gzip_level"`
+	// BrotliLevel is between 1 (best speed) and 11 (best compression).
+	BrotliLevel int `yaml:"brotli_level"`
+}
+
+// RateLimitConfig contains limits of requests public API clients can make, clients are
+// identified by API key or IP address.
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Default is a limit of routes missing in Routes.
+	Default RateLimit `yaml:"default"`
+	// Routes contains limits per route, e.g. 'positions' or 'export'.
+	Routes map[string]RateLimit `yaml:"routes"`
+	// TrustedProxies contains addresses or CIDR networks of reverse proxies, client addresses
+	// are taken from X-Forwarded-For and X-Real-IP headers only if requests come from them.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// TrustedNetworks parses addresses of trusted proxies, an address is a network of a single host.
+func (c RateLimitConfig) TrustedNetworks() ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(c.TrustedProxies))
+	for _, proxy := range c.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy '%s' must be an IP address or a CIDR network", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy '%s' must be an IP address or a CIDR network", proxy)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// RateLimit allows a client Burst requests at once and then Rate requests per second.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// UsageConfig contains configuration of API keys usage metering, it requires authentication.
+type UsageConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// DefaultQuota is a monthly quota of keys missing in Quotas.
+	DefaultQuota Quota `yaml:"default_quota"`
+	// Quotas contains monthly quotas per API key ID.
+	Quotas map[string]Quota `yaml:"quotas"`
+	// FlushInterval is a number of seconds usage is aggregated in memory for before it's stored.
+	FlushInterval int `yaml:"flush_interval"`
+}
+
+// Quota limits usage of an API key per calendar month, zero means no limit.
+type Quota struct {
+	Requests    int64 `yaml:"requests"`
+	Rows        int64 `yaml:"rows"`
+	ExportBytes int64 `yaml:"export_bytes"`
 }
 
 // ServiceAPIServerConfig contains configuration to provide service REST API.
@@ -64,7 +202,14 @@ type ServiceAPIServerConfig struct {
 
 // DBConfig contains DB-related configuration.
 type DBConfig struct {
+	// Driver is one of DBDriverSQLite, DBDriverPostgres or DBDriverMemory.
+	Driver string `yaml:"driver"`
+	// DSN is a path to SQLite database file or PostgreSQL connection string, it's ignored by
+	// DBDriverMemory.
 	DSN string `yaml:"dsn"`
+	// AutoMigrate makes the service apply pending migrations on start instead of refusing
+	// to start, it's always set for DBDriverMemory.
+	AutoMigrate bool `yaml:"auto_migrate"`
 }
 
 // SentryConfig contains sentry specific configuration.
@@ -74,6 +219,27 @@ type SentryConfig struct {
 	Enabled     bool   `yaml:"enabled"`
 }
 
+// TrafficConfig contains parameters of organic traffic estimation.
+type TrafficConfig struct {
+	// CTR contains click-through rates of positions starting from the first one,
+	// positions beyond the curve get no traffic.
+	CTR []float64 `yaml:"ctr"`
+}
+
+// CacheConfig contains configuration of the in-process cache of summaries and positions.
+type CacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Size is the max number of cached query results.
+	Size int `yaml:"size"`
+	// TTL is a number of seconds a cached result is served for.
+	TTL int `yaml:"ttl"`
+	// WarmUp contains domains whose summaries and the first page of positions are cached on start.
+	WarmUp []string `yaml:"warm_up"`
+	// PollInterval is a number of seconds between checks of writes made by other processes,
+	// e.g. 'import' command, the cache is purged once they're found.
+	PollInterval int `yaml:"poll_interval"`
+}
+
 // CheckConfig helps to check if global application config is ready.
 func CheckConfig() error {
 	if Config == nil {
@@ -112,7 +278,7 @@ func initFromString(data []byte) error {
 	defaultStringParameters := map[*string]string{
 		&Config.PublicAPI.ServerAddress:  defaultPublicAPIAddress,
 		&Config.ServiceAPI.ServerAddress: defaultServiceAPIAddress,
-		&Config.DB.DSN:                   defaultSQliteDSN,
+		&Config.DB.Driver:                DBDriverSQLite,
 	}
 	for currentValue, defaultValue := range defaultStringParameters {
 		setDefaultStringValue(currentValue, defaultValue)
@@ -121,23 +287,109 @@ func initFromString(data []byte) error {
 	// Set default int parameters if omitted.
 	defaultIntParameters := map[*int]int{
 		// Public API defaults
-		&Config.PublicAPI.ServerPort:   defaultPublicAPIPort,
-		&Config.PublicAPI.ReadTimeout:  defaultHTTPReadTimeout,
-		&Config.PublicAPI.WriteTimeout: defaultHTTPWriteTimeout,
-		&Config.PublicAPI.IdleTimeout:  defaultHTTPIdleTimeout,
+		&Config.PublicAPI.ServerPort:          defaultPublicAPIPort,
+		&Config.PublicAPI.ReadTimeout:         defaultHTTPReadTimeout,
+		&Config.PublicAPI.WriteTimeout:        defaultHTTPWriteTimeout,
+		&Config.PublicAPI.ExportWriteTimeout:  defaultExportWriteTimeout,
+		&Config.PublicAPI.IdleTimeout:         defaultHTTPIdleTimeout,
+		&Config.PublicAPI.PositionsPerPage:    defaultPositionsPerPage,
+		&Config.PublicAPI.MaxPositionsPerPage: defaultMaxPositionsPerPage,
+		// Public API compression defaults
+		&Config.PublicAPI.Compression.MinSize:     defaultCompressionMinSize,
+		&Config.PublicAPI.Compression.GzipLevel:   defaultCompressionGzipLevel,
+		&Config.PublicAPI.Compression.BrotliLevel: defaultCompressionBrotliLevel,
+		// Public API rate limit defaults
+		&Config.PublicAPI.RateLimit.Default.Burst: defaultRateLimitBurst,
+		// Public API usage defaults
+		&Config.PublicAPI.Usage.FlushInterval: defaultUsageFlushInterval,
 		// ServiceAPI defaults
 		&Config.ServiceAPI.ServerPort:   defaultServiceAPIPort,
 		&Config.ServiceAPI.ReadTimeout:  defaultHTTPReadTimeout,
 		&Config.ServiceAPI.WriteTimeout: defaultHTTPWriteTimeout,
 		&Config.ServiceAPI.IdleTimeout:  defaultHTTPIdleTimeout,
+		// Cache defaults
+		&Config.Cache.Size:         defaultCacheSize,
+		&Config.Cache.TTL:          defaultCacheTTL,
+		&Config.Cache.PollInterval: defaultCachePollInterval,
 	}
 	for currentValue, defaultValue := range defaultIntParameters {
 		setDefaultIntValue(currentValue, defaultValue)
 	}
 
+	switch Config.DB.Driver {
+	case DBDriverSQLite:
+		setDefaultStringValue(&Config.DB.DSN, defaultSQliteDSN)
+	case DBDriverPostgres:
+		if Config.DB.DSN == "" {
+			return errors.New("DSN is required by postgres DB driver")
+		}
+	case DBDriverMemory:
+		Config.DB.AutoMigrate = true
+	default:
+		return fmt.Errorf("unknown DB driver '%s'", Config.DB.Driver)
+	}
+
+	if len(Config.Traffic.CTR) == 0 {
+		Config.Traffic.CTR = append([]float64(nil), DefaultCTRCurve...)
+	}
+	for i, ctr := range Config.Traffic.CTR {
+		if ctr < 0 || ctr > 1 {
+			return fmt.Errorf("CTR of position %d must be between 0 and 1", i+1)
+		}
+	}
+
+	if Config.PublicAPI.Compression.GzipLevel > maxCompressionGzipLevel {
+		return fmt.Errorf("gzip level must be between 1 and %d", maxCompressionGzipLevel)
+	}
+	if Config.PublicAPI.Compression.BrotliLevel > maxCompressionBrotliLevel {
+		return fmt.Errorf("brotli level must be between 1 and %d", maxCompressionBrotliLevel)
+	}
+
+	if Config.PublicAPI.RateLimit.Default.Rate < 0 {
+		return errors.New("default rate limit must not be negative")
+	}
+	if Config.PublicAPI.RateLimit.Default.Rate == 0 {
+		Config.PublicAPI.RateLimit.Default.Rate = defaultRateLimitRate
+	}
+	for route, limit := range Config.PublicAPI.RateLimit.Routes {
+		if limit.Rate <= 0 || limit.Burst <= 0 {
+			return fmt.Errorf("rate limit of route '%s' must have positive rate and burst", route)
+		}
+	}
+	if _, err := Config.PublicAPI.RateLimit.TrustedNetworks(); err != nil {
+		return err
+	}
+
+	if usage := Config.PublicAPI.Usage; usage.Enabled {
+		if !Config.PublicAPI.Auth.Enabled {
+			return errors.New("usage metering requires authentication to be enabled")
+		}
+		if !usage.DefaultQuota.valid() {
+			return errors.New("default quota must not be negative")
+		}
+		for id, quota := range usage.Quotas {
+			if !quota.valid() {
+				return fmt.Errorf("quota of API key '%s' must not be negative", id)
+			}
+		}
+	}
+
+	if Config.PublicAPI.DefaultMaxAge < 0 {
+		return errors.New("default max-age must not be negative")
+	}
+	for route, maxAge := range Config.PublicAPI.MaxAge {
+		if maxAge < 0 {
+			return fmt.Errorf("max-age of route '%s' must not be negative", route)
+		}
+	}
+
 	return nil
 }
 
+func (q Quota) valid() bool {
+	return q.Requests >= 0 && q.Rows >= 0 && q.ExportBytes >= 0
+}
+
 func setDefaultIntValue(currentValue *int, defaultValue int) {
 	if *currentValue <= 0 {
 		*currentValue = defaultValue
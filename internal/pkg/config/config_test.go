@@ -18,8 +18,44 @@ public_api:
   read_timeout: 15
   write_timeout: 20
   idle_timeout: 30
+  export_write_timeout: 600
+  positions_per_page: 20
+  max_positions_per_page: 50
+  default_max_age: 60
+  max_age:
+    summary: 300
+    search: 0
+  compression:
+    enabled: true
+    min_size: 512
+    gzip_level: 9
+    brotli_level: 11
+  rate_limit:
+    enabled: true
+    default:
+      rate: 5
+      burst: 10
+    routes:
+      export:
+        rate: 0.1
+        burst: 1
+    trusted_proxies:
+      - 10.0.0.0/8
+  auth:
+    enabled: true
+  usage:
+    enabled: true
+    default_quota:
+      requests: 100000
+      rows: 1000000
+    quotas:
+      f13948d267ed:
+        export_bytes: 1073741824
+    flush_interval: 30
 db:
-  dsn: test_positions.db
+  driver: postgres
+  dsn: postgres://localhost/positions
+  auto_migrate: true
 service_api:
   server_address: localhost
   server_port: 63101
@@ -30,6 +66,14 @@ sentry:
   enabled: true
   dsn: some_sentry_dsn
   environment: dev
+traffic:
+  ctr: [0.3, 0.2, 0.1]
+cache:
+  enabled: true
+  size: 500
+  ttl: 30
+  warm_up: [ulmart.ru, fidel.net]
+  poll_interval: 5
 `
 
 	expected := &AppConfig{
@@ -44,9 +88,37 @@ sentry:
 			ReadTimeout:   15,
 			WriteTimeout:  20,
 			IdleTimeout:   30,
+
+			ExportWriteTimeout: 600,
+
+			PositionsPerPage:    20,
+			MaxPositionsPerPage: 50,
+			DefaultMaxAge:       60,
+			MaxAge:              map[string]int{"summary": 300, "search": 0},
+			Compression: CompressionConfig{
+				Enabled:     true,
+				MinSize:     512,
+				GzipLevel:   9,
+				BrotliLevel: 11,
+			},
+			RateLimit: RateLimitConfig{
+				Enabled:        true,
+				Default:        RateLimit{Rate: 5, Burst: 10},
+				Routes:         map[string]RateLimit{"export": {Rate: 0.1, Burst: 1}},
+				TrustedProxies: []string{"10.0.0.0/8"},
+			},
+			Auth: AuthConfig{Enabled: true},
+			Usage: UsageConfig{
+				Enabled:       true,
+				DefaultQuota:  Quota{Requests: 100000, Rows: 1000000},
+				Quotas:        map[string]Quota{"f13948d267ed": {ExportBytes: 1073741824}},
+				FlushInterval: 30,
+			},
 		},
 		DB: DBConfig{
-			DSN: "test_positions.db",
+			Driver:      "postgres",
+			DSN:         "postgres://localhost/positions",
+			AutoMigrate: true,
 		},
 		ServiceAPI: ServiceAPIServerConfig{
 			ServerAddress: "localhost",
@@ -60,6 +132,16 @@ sentry:
 			Enabled:     true,
 			Environment: "dev",
 		},
+		Traffic: TrafficConfig{
+			CTR: []float64{0.3, 0.2, 0.1},
+		},
+		Cache: CacheConfig{
+			Enabled:      true,
+			Size:         500,
+			TTL:          30,
+			WarmUp:       []string{"ulmart.ru", "fidel.net"},
+			PollInterval: 5,
+		},
 	}
 
 	err := initFromString([]byte(configString))
@@ -78,9 +160,26 @@ func TestConfigInitFromStringDefaultValues(t *testing.T) {
 			ReadTimeout:   60,
 			WriteTimeout:  120,
 			IdleTimeout:   240,
+
+			ExportWriteTimeout: 3600,
+
+			PositionsPerPage:    10,
+			MaxPositionsPerPage: 100,
+			Compression: CompressionConfig{
+				MinSize:     1024,
+				GzipLevel:   6,
+				BrotliLevel: 5,
+			},
+			RateLimit: RateLimitConfig{
+				Default: RateLimit{Rate: 10, Burst: 20},
+			},
+			Usage: UsageConfig{
+				FlushInterval: 10,
+			},
 		},
 		DB: DBConfig{
-			DSN: "data/positions.db",
+			Driver: "sqlite",
+			DSN:    "data/positions.db",
 		},
 		ServiceAPI: ServiceAPIServerConfig{
 			ServerAddress: "127.0.0.1",
@@ -89,6 +188,14 @@ func TestConfigInitFromStringDefaultValues(t *testing.T) {
 			WriteTimeout:  120,
 			IdleTimeout:   240,
 		},
+		Traffic: TrafficConfig{
+			CTR: DefaultCTRCurve,
+		},
+		Cache: CacheConfig{
+			Size:         1000,
+			TTL:          60,
+			PollInterval: 1,
+		},
 	}
 
 	err := initFromString([]byte(configString))
@@ -97,6 +204,71 @@ func TestConfigInitFromStringDefaultValues(t *testing.T) {
 	assert.Equal(t, expected, Config)
 }
 
+func TestConfigInitFromStringInvalidCTR(t *testing.T) {
+	err := initFromString([]byte("traffic:\n  ctr: [0.3, 1.5]\n"))
+
+	assert.EqualError(t, err, "CTR of position 2 must be between 0 and 1")
+}
+
+func TestConfigInitFromStringInvalidDB(t *testing.T) {
+	err := initFromString([]byte("db:\n  driver: mysql\n"))
+	assert.EqualError(t, err, "unknown DB driver 'mysql'")
+
+	err = initFromString([]byte("db:\n  driver: postgres\n"))
+	assert.EqualError(t, err, "DSN is required by postgres DB driver")
+
+	err = initFromString([]byte("db:\n  driver: memory\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, DBConfig{Driver: DBDriverMemory, AutoMigrate: true}, Config.DB)
+}
+
+func TestConfigInitFromStringInvalidMaxAge(t *testing.T) {
+	err := initFromString([]byte("public_api:\n  default_max_age: -1\n"))
+	assert.EqualError(t, err, "default max-age must not be negative")
+
+	err = initFromString([]byte("public_api:\n  max_age:\n    summary: -1\n"))
+	assert.EqualError(t, err, "max-age of route 'summary' must not be negative")
+}
+
+func TestConfigInitFromStringInvalidCompression(t *testing.T) {
+	err := initFromString([]byte("public_api:\n  compression:\n    gzip_level: 10\n"))
+	assert.EqualError(t, err, "gzip level must be between 1 and 9")
+
+	err = initFromString([]byte("public_api:\n  compression:\n    brotli_level: 12\n"))
+	assert.EqualError(t, err, "brotli level must be between 1 and 11")
+}
+
+func TestConfigInitFromStringInvalidRateLimit(t *testing.T) {
+	err := initFromString([]byte("public_api:\n  rate_limit:\n    default:\n      rate: -1\n"))
+	assert.EqualError(t, err, "default rate limit must not be negative")
+
+	err = initFromString([]byte("public_api:\n  rate_limit:\n    routes:\n      export:\n        rate: 1\n"))
+	assert.EqualError(t, err, "rate limit of route 'export' must have positive rate and burst")
+
+	err = initFromString([]byte("public_api:\n  rate_limit:\n    trusted_proxies: [10.0.0.0/8, 10.0.0]\n"))
+	assert.EqualError(t, err, "trusted proxy '10.0.0' must be an IP address or a CIDR network")
+}
+
+func TestRateLimitConfigTrustedNetworks(t *testing.T) {
+	networks, err := RateLimitConfig{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1", "::1"}}.TrustedNetworks()
+	assert.NoError(t, err)
+	assert.Len(t, networks, 3)
+	assert.Equal(t, "10.0.0.0/8", networks[0].String())
+	assert.Equal(t, "192.168.1.1/32", networks[1].String())
+	assert.Equal(t, "::1/128", networks[2].String())
+}
+
+func TestConfigInitFromStringInvalidUsage(t *testing.T) {
+	err := initFromString([]byte("public_api:\n  usage:\n    enabled: true\n"))
+	assert.EqualError(t, err, "usage metering requires authentication to be enabled")
+
+	err = initFromString([]byte("public_api:\n  auth:\n    enabled: true\n  usage:\n    enabled: true\n    default_quota:\n      rows: -1\n"))
+	assert.EqualError(t, err, "default quota must not be negative")
+
+	err = initFromString([]byte("public_api:\n  auth:\n    enabled: true\n  usage:\n    enabled: true\n    quotas:\n      abc:\n        requests: -1\n"))
+	assert.EqualError(t, err, "quota of API key 'abc' must not be negative")
+}
+
 func TestCheckConfigErr(t *testing.T) {
 	Config = nil
 
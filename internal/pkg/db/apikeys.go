@@ -0,0 +1,274 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	insertAPIKeyQuery = `INSERT INTO api_keys (id, name, key_hash, scopes, domains, created)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	selectAPIKeysQuery = `SELECT id, name, scopes, domains, created, revoked FROM api_keys ORDER BY created, id`
+
+	getAPIKeyByHashQuery = `SELECT id, name, scopes, domains, created, revoked
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked = 0`
+
+	revokeAPIKeyQuery = `UPDATE api_keys SET revoked = $1 WHERE id = $2 AND revoked = 0`
+)
+
+// Scopes of API keys.
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeExport = "export"
+)
+
+// Scopes contains all scopes an API key can be granted.
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeExport}
+
+// apiKeyPrefix makes keys recognizable, e.g. by secret scanners.
+const apiKeyPrefix = "sb_"
+
+// ErrAPIKeyNotFound is returned for unknown and revoked API keys.
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// APIKey represents a key clients of the public API authenticate with, the key itself is
+// known only to its owner.
+type APIKey struct {
+	ID     string
+	Name   string
+	Scopes []string
+	// Domains the key has access to, empty list means all domains.
+	Domains []string
+	Created time.Time
+	// Revoked is zero for active keys.
+	Revoked time.Time
+}
+
+// HasScope reports whether the key is granted the scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsDomain reports whether the key has access to the domain.
+func (k *APIKey) AllowsDomain(domain string) bool {
+	if k.AllDomains() {
+		return true
+	}
+	for _, d := range k.Domains {
+		if d == domain {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllDomains reports whether the key isn't restricted to a list of domains.
+func (k *APIKey) AllDomains() bool {
+	return len(k.Domains) == 0
+}
+
+// CreateAPIKeyOpts contains parameters of a new API key.
+type CreateAPIKeyOpts struct {
+	Name    string
+	Scopes  []string
+	Domains []string
+}
+
+// APIKeyRepo represents a data access layer to 'api_keys' table.
+type APIKeyRepo struct {
+	conn *sqlx.DB
+	log  *zap.Logger
+}
+
+// NewAPIKeyRepo returns new instance of APIKeyRepo.
+func NewAPIKeyRepo(log *zap.Logger, conn *sqlx.DB) *APIKeyRepo {
+	return &APIKeyRepo{
+		conn: conn,
+		log:  log,
+	}
+}
+
+// CreateAPIKey generates a new key and stores its hash, the key is returned only once.
+func (kr *APIKeyRepo) CreateAPIKey(ctx context.Context, opts *CreateAPIKeyOpts) (*APIKey, string, error) {
+	if strings.TrimSpace(opts.Name) == "" {
+		return nil, "", errors.New("name is required")
+	}
+	if len(opts.Scopes) == 0 {
+		return nil, "", errors.New("at least one scope is required")
+	}
+	for _, scope := range opts.Scopes {
+		if !isScope(scope) {
+			return nil, "", fmt.Errorf("unknown scope '%s', expected one of: %s", scope, strings.Join(Scopes, ", "))
+		}
+	}
+	for _, domain := range opts.Domains {
+		if domain == "" || strings.Contains(domain, ",") {
+			return nil, "", fmt.Errorf("domain '%s' is invalid", domain)
+		}
+	}
+
+	id, err := randomHex(6)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+	key := apiKeyPrefix + id + "_" + secret
+
+	k := &APIKey{
+		ID:      id,
+		Name:    opts.Name,
+		Scopes:  opts.Scopes,
+		Domains: opts.Domains,
+		Created: time.Now().UTC().Truncate(time.Second),
+	}
+	if _, err := kr.conn.ExecContext(ctx, insertAPIKeyQuery, k.ID, k.Name, HashAPIKey(key),
+		strings.Join(k.Scopes, ","), strings.Join(k.Domains, ","), k.Created.Unix()); err != nil {
+		kr.log.Error("failed to insert API key", zap.Error(err))
+
+		return nil, "", fmt.Errorf("failed to insert API key: %w", err)
+	}
+
+	return k, key, nil
+}
+
+// ListAPIKeys returns all keys including revoked ones ordered by creation time.
+func (kr *APIKeyRepo) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	rows, err := kr.conn.QueryContext(ctx, selectAPIKeysQuery)
+	if err != nil {
+		kr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]*APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			kr.log.Error("failed to scan API key", zap.Error(err))
+
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// Authenticate returns an active key by its value.
+func (kr *APIKeyRepo) Authenticate(ctx context.Context, key string) (*APIKey, error) {
+	k, err := scanAPIKey(kr.conn.QueryRowxContext(ctx, getAPIKeyByHashQuery, HashAPIKey(key)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		kr.log.Error("failed to get API key", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return k, nil
+}
+
+// RevokeAPIKey makes the key unusable, revoked keys are kept to be listed.
+func (kr *APIKeyRepo) RevokeAPIKey(ctx context.Context, id string) error {
+	res, err := kr.conn.ExecContext(ctx, revokeAPIKeyQuery, time.Now().Unix(), id)
+	if err != nil {
+		kr.log.Error("failed to revoke API key", zap.Error(err))
+
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	revoked, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if revoked == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// HashAPIKey returns a hash the key is stored with. Keys are random, so they don't need
+// a slow salted hash as passwords do.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// rowScanner is implemented by both sql.Rows and sqlx.Row.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var (
+		k                = &APIKey{}
+		scopes, domains  string
+		created, revoked int64
+	)
+	if err := row.Scan(&k.ID, &k.Name, &scopes, &domains, &created, &revoked); err != nil {
+		return nil, err
+	}
+
+	k.Scopes = splitList(scopes)
+	k.Domains = splitList(domains)
+	k.Created = time.Unix(created, 0).UTC()
+	if revoked != 0 {
+		k.Revoked = time.Unix(revoked, 0).UTC()
+	}
+
+	return k, nil
+}
+
+// splitList splits a comma-separated list, empty string is an empty list.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
+
+func isScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
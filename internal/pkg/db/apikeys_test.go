@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/log"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeys(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.CreateSchema(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	ctx := context.Background()
+	repo := NewAPIKeyRepo(logger, b.DB)
+
+	created, key, err := repo.CreateAPIKey(ctx, &CreateAPIKeyOpts{
+		Name:    "reports",
+		Scopes:  []string{ScopeRead, ScopeExport},
+		Domains: []string{testutils.TestDomain},
+	})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "sb_"+created.ID+"_"))
+
+	// Only the hash of the key is stored
+	var stored int
+	assert.NoError(t, b.DB.Get(&stored, `SELECT COUNT(1) FROM api_keys WHERE key_hash = $1`, key))
+	assert.Equal(t, 0, stored)
+
+	got, err := repo.Authenticate(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, created, got)
+	assert.True(t, got.HasScope(ScopeExport))
+	assert.False(t, got.HasScope(ScopeWrite))
+	assert.True(t, got.AllowsDomain(testutils.TestDomain))
+	assert.False(t, got.AllowsDomain("fidel.net"))
+
+	_, err = repo.Authenticate(ctx, key+"0")
+	assert.Equal(t, ErrAPIKeyNotFound, err)
+
+	assert.NoError(t, repo.RevokeAPIKey(ctx, created.ID))
+	assert.Equal(t, ErrAPIKeyNotFound, repo.RevokeAPIKey(ctx, created.ID))
+	_, err = repo.Authenticate(ctx, key)
+	assert.Equal(t, ErrAPIKeyNotFound, err)
+
+	keys, err := repo.ListAPIKeys(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, created.ID, keys[0].ID)
+	assert.False(t, keys[0].Revoked.IsZero())
+}
+
+func TestCreateAPIKey_Invalid(t *testing.T) {
+	repo := NewAPIKeyRepo(nil, nil)
+
+	_, _, err := repo.CreateAPIKey(context.Background(), &CreateAPIKeyOpts{Scopes: []string{ScopeRead}})
+	assert.EqualError(t, err, "name is required")
+
+	_, _, err = repo.CreateAPIKey(context.Background(), &CreateAPIKeyOpts{Name: "test"})
+	assert.EqualError(t, err, "at least one scope is required")
+
+	_, _, err = repo.CreateAPIKey(context.Background(), &CreateAPIKeyOpts{Name: "test", Scopes: []string{"admin"}})
+	assert.EqualError(t, err, "unknown scope 'admin', expected one of: read, write, export")
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// likeEscape is an escape character of LIKE patterns built by queryBuilder.
+const likeEscape = `\`
+
+// queryBuilder builds parameterized SQL conditions. Values are never put into the
+// query text, only their placeholders are.
+type queryBuilder struct {
+	conditions []string
+	args       []interface{}
+
+	// like is an operator of LIKE conditions, LIKE is used if it's empty.
+	like string
+}
+
+// bind adds an argument to the query and returns its placeholder.
+func (qb *queryBuilder) bind(arg interface{}) string {
+	qb.args = append(qb.args, arg)
+
+	return "$" + strconv.Itoa(len(qb.args))
+}
+
+// where adds a condition, every '?' in the condition is replaced with a placeholder of the
+// corresponding argument. The condition itself must never contain user input.
+func (qb *queryBuilder) where(condition string, args ...interface{}) {
+	var sb strings.Builder
+	for _, arg := range args {
+		i := strings.IndexByte(condition, '?')
+		sb.WriteString(condition[:i])
+		sb.WriteString(qb.bind(arg))
+		condition = condition[i+1:]
+	}
+	sb.WriteString(condition)
+
+	qb.conditions = append(qb.conditions, sb.String())
+}
+
+// whereLike adds a LIKE condition with the given pattern, the value is escaped so it
+// can't contain wildcards.
+func (qb *queryBuilder) whereLike(column, prefix, value, suffix string) {
+	like := qb.like
+	if like == "" {
+		like = "LIKE"
+	}
+
+	qb.where(column+" "+like+` ? ESCAPE '`+likeEscape+`'`, prefix+escapeLike(value)+suffix)
+}
+
+// whereSQL returns a WHERE clause joining all conditions with AND.
+func (qb *queryBuilder) whereSQL() string {
+	if len(qb.conditions) == 0 {
+		return ""
+	}
+
+	return "WHERE " + strings.Join(qb.conditions, " AND ")
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(
+		likeEscape, likeEscape+likeEscape,
+		"%", likeEscape+"%",
+		"_", likeEscape+"_",
+	).Replace(value)
+}
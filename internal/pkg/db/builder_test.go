@@ -0,0 +1,56 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryBuilderWhere(t *testing.T) {
+	qb := &queryBuilder{}
+	assert.Equal(t, "", qb.whereSQL())
+
+	qb.where("domain = ?", "ulmart.ru")
+	qb.where("position BETWEEN ? AND ?", 1, 10)
+	qb.whereLike("keyword", "%", `50%_off\`, "%")
+
+	assert.Equal(t, `WHERE domain = $1 AND position BETWEEN $2 AND $3 AND keyword LIKE $4 ESCAPE '\'`, qb.whereSQL())
+	assert.Equal(t, []interface{}{"ulmart.ru", 1, 10, `%50\%\_off\\%`}, qb.args)
+	assert.Equal(t, "$5", qb.bind(10))
+}
+
+func TestPositionsFilterApply(t *testing.T) {
+	minPosition, maxPosition, minVolume := 1, 10, 1000
+	minCPC := 0.5
+	updatedFrom := time.Date(2017, 5, 15, 0, 0, 0, 0, time.UTC)
+	updatedTo := time.Date(2017, 5, 20, 0, 0, 0, 0, time.UTC)
+
+	f := &PositionsFilter{
+		MinPosition:     &minPosition,
+		MaxPosition:     &maxPosition,
+		MinVolume:       &minVolume,
+		MinCPC:          &minCPC,
+		UpdatedFrom:     &updatedFrom,
+		UpdatedTo:       &updatedTo,
+		KeywordContains: "shoe",
+		URLPrefix:       "https://fidel.net/",
+	}
+
+	qb := &queryBuilder{}
+	f.apply(qb)
+
+	assert.Equal(t, "WHERE position >= $1 AND position <= $2 AND volume >= $3 AND cpc >= $4 AND "+
+		"updated >= $5 AND updated < $6 AND keyword LIKE $7 ESCAPE '\\' AND url LIKE $8 ESCAPE '\\'", qb.whereSQL())
+	assert.Equal(t, []interface{}{1, 10, 1000, 0.5, int64(1494806400), int64(1495324800),
+		"%shoe%", "https://fidel.net/%"}, qb.args)
+}
+
+func TestPositionsFilterApplyNil(t *testing.T) {
+	var f *PositionsFilter
+
+	qb := &queryBuilder{}
+	f.apply(qb)
+
+	assert.Empty(t, qb.conditions)
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/cache"
+	"go.uber.org/zap"
+)
+
+// CachedRepo caches summaries, pages of positions and versions of positions returned by
+// the repository, the cache is purged on every write made through it. Writes of other
+// processes are found by WatchVersion. Cached values are
+// shared between callers, so they must not be modified.
+type CachedRepo struct {
+	Repository
+	cache *cache.LRU
+}
+
+var _ Repository = (*CachedRepo)(nil)
+
+// NewCachedRepo returns new instance of CachedRepo.
+func NewCachedRepo(repo Repository, c *cache.LRU) *CachedRepo {
+	return &CachedRepo{
+		Repository: repo,
+		cache:      c,
+	}
+}
+
+// load returns a cached result of the method called with the arguments, results are keyed
+// by the method name and JSON of the arguments.
+func (cr *CachedRepo) load(method string, args interface{}, load func() (interface{}, error)) (interface{}, error) {
+	key, err := json.Marshal(args)
+	if err != nil {
+		return load()
+	}
+
+	return cr.cache.Load(method+string(key), load)
+}
+
+func (cr *CachedRepo) GetSummary(ctx context.Context, domain string) (int, error) {
+	v, err := cr.load("GetSummary", domain, func() (interface{}, error) {
+		return cr.Repository.GetSummary(ctx, domain)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return v.(int), nil
+}
+
+func (cr *CachedRepo) GetDomainSummary(ctx context.Context, domain string) (*DomainSummary, error) {
+	v, err := cr.load("GetDomainSummary", domain, func() (interface{}, error) {
+		return cr.Repository.GetDomainSummary(ctx, domain)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*DomainSummary), nil
+}
+
+func (cr *CachedRepo) CountPositions(ctx context.Context, domain string, filter *PositionsFilter) (int, error) {
+	args := struct {
+		Domain string
+		Filter *PositionsFilter
+	}{domain, filter}
+
+	v, err := cr.load("CountPositions", args, func() (interface{}, error) {
+		return cr.Repository.CountPositions(ctx, domain, filter)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return v.(int), nil
+}
+
+func (cr *CachedRepo) GetDatasetVersion(ctx context.Context, domain string) (*DatasetVersion, error) {
+	v, err := cr.load("GetDatasetVersion", domain, func() (interface{}, error) {
+		return cr.Repository.GetDatasetVersion(ctx, domain)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*DatasetVersion), nil
+}
+
+func (cr *CachedRepo) GetPositions(ctx context.Context, opts *GetPositionsOpts) ([]*Position, error) {
+	v, err := cr.load("GetPositions", opts, func() (interface{}, error) {
+		return cr.Repository.GetPositions(ctx, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]*Position), nil
+}
+
+func (cr *CachedRepo) UpsertPositions(ctx context.Context, domain string, positions []*Position) (*UpsertResult, error) {
+	defer cr.cache.Purge()
+
+	return cr.Repository.UpsertPositions(ctx, domain, positions)
+}
+
+func (cr *CachedRepo) ImportPositions(ctx context.Context, batch []*DomainPositions, dryRun bool) (*UpsertResult, error) {
+	if !dryRun {
+		defer cr.cache.Purge()
+	}
+
+	return cr.Repository.ImportPositions(ctx, batch, dryRun)
+}
+
+// WarmUp caches summaries, the first page of positions of the given size and versions of the domains
+// the way handlers request them without query parameters.
+func (cr *CachedRepo) WarmUp(ctx context.Context, domains []string, limit int) error {
+	filter := &PositionsFilter{}
+	for _, domain := range domains {
+		if _, err := cr.GetSummary(ctx, domain); err != nil {
+			return fmt.Errorf("failed to warm up summary of %s: %w", domain, err)
+		}
+		if _, err := cr.GetDomainSummary(ctx, domain); err != nil {
+			return fmt.Errorf("failed to warm up domain summary of %s: %w", domain, err)
+		}
+		// Handlers select one more position to find out if there's the next page
+		if _, err := cr.GetPositions(ctx, &GetPositionsOpts{Domain: domain, Filter: filter, Limit: limit + 1}); err != nil {
+			return fmt.Errorf("failed to warm up positions of %s: %w", domain, err)
+		}
+		if _, err := cr.CountPositions(ctx, domain, filter); err != nil {
+			return fmt.Errorf("failed to warm up positions count of %s: %w", domain, err)
+		}
+		if _, err := cr.GetDatasetVersion(ctx, domain); err != nil {
+			return fmt.Errorf("failed to warm up dataset version of %s: %w", domain, err)
+		}
+	}
+
+	return nil
+}
+
+// WatchVersion purges the cache once positions are written by another process, e.g. by 'import'
+// command. The version of all positions is read by the repository every interval until the
+// context is done, the repository must not be cached.
+func WatchVersion(ctx context.Context, log *zap.Logger, repo Repository, c *cache.LRU, interval time.Duration) error {
+	seen, err := repo.GetDatasetVersion(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			v, err := repo.GetDatasetVersion(ctx, "")
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Warn("failed to check dataset version", zap.Error(err))
+				}
+
+				continue
+			}
+			if v.Version != seen.Version {
+				log.Debug("positions are written, purge cache", zap.Int64("version", v.Version))
+				c.Purge()
+				seen = v
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,249 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Types of position changes between two dates.
+const (
+	ChangeNew       = "new"
+	ChangeLost      = "lost"
+	ChangeImproved  = "improved"
+	ChangeDeclined  = "declined"
+	ChangeUnchanged = "unchanged"
+)
+
+// TrackedPositions is a number of top positions a keyword is considered ranked within,
+// positions beyond it are treated as lost.
+const TrackedPositions = 100
+
+// ChangesOrderBy is the order of position changes, the biggest movers go first. Cursors
+// of changes are decoded with it.
+const ChangesOrderBy = "-abs_impact,-abs_delta"
+
+const (
+	// changesCTE compares the latest versions of positions observed before the end of the
+	// 'from' day ($3) and the 'to' day ($2). Current positions are a part of versions, so
+	// the previous version is always among them if it exists. Missing and unranked
+	// positions count as TrackedPositions+1.
+	changesCTE = `WITH versions AS (
+			SELECT keyword, url, position, volume, updated
+			FROM positions_history
+			WHERE domain = $1 AND updated < $2
+			UNION
+			SELECT keyword, url, position, volume, CAST(updated AS BIGINT)
+			FROM positions
+			WHERE domain = $1 AND CAST(updated AS BIGINT) < $2
+		), latest AS (
+			SELECT
+					keyword,
+					url,
+					MAX(CASE WHEN updated < $3 THEN updated END) AS previous_updated,
+					MAX(updated) AS current_updated
+			FROM versions
+			GROUP BY keyword, url
+		), compared AS (
+			SELECT
+					cur.keyword,
+					cur.url,
+					prev.position AS previous_position,
+					cur.position,
+					cur.volume,
+					CASE WHEN prev.position <= %[1]d THEN prev.position ELSE %[2]d END -
+						CASE WHEN cur.position <= %[1]d THEN cur.position ELSE %[2]d END AS delta
+			FROM latest
+			JOIN versions cur ON cur.keyword = latest.keyword AND cur.url = latest.url
+				AND cur.updated = latest.current_updated
+			LEFT JOIN versions prev ON prev.keyword = latest.keyword AND prev.url = latest.url
+				AND prev.updated = latest.previous_updated
+			WHERE prev.position <= %[1]d OR cur.position <= %[1]d
+		), changes AS (
+			SELECT
+					keyword,
+					url,
+					CASE
+						WHEN previous_position IS NULL OR previous_position > %[1]d THEN '` + ChangeNew + `'
+						WHEN position > %[1]d THEN '` + ChangeLost + `'
+						WHEN delta > 0 THEN '` + ChangeImproved + `'
+						WHEN delta < 0 THEN '` + ChangeDeclined + `'
+						ELSE '` + ChangeUnchanged + `'
+					END AS change_type,
+					previous_position,
+					position,
+					delta,
+					volume,
+					CAST(delta AS BIGINT) * volume AS impact,
+					ABS(delta) AS abs_delta,
+					ABS(CAST(delta AS BIGINT) * volume) AS abs_impact
+			FROM compared
+		)
+`
+
+	selectChangesQuery = `SELECT keyword, url, change_type, previous_position, position, delta, volume, impact
+		FROM changes
+		%s
+		ORDER BY %s
+`
+
+	countChangesQuery = `SELECT change_type, COUNT(1) FROM changes GROUP BY change_type`
+)
+
+// PositionChange represents a change of a keyword's position of an url between two dates.
+type PositionChange struct {
+	Keyword string `json:"keyword"`
+	URL     string `json:"url"`
+	Type    string `json:"type"`
+
+	// PreviousPosition and Position are nil if there's no version of the position at the date.
+	PreviousPosition *int `json:"previous_position"`
+	Position         *int `json:"position"`
+
+	// Delta is positive if the position has improved, missing positions count as TrackedPositions+1.
+	Delta  int `json:"delta"`
+	Volume int `json:"volume"`
+	// Impact is the delta weighted by search volume.
+	Impact int `json:"impact"`
+}
+
+// GetChangesOpts contains parameters of position changes selection, both dates are inclusive.
+type GetChangesOpts struct {
+	Domain string
+	From   time.Time
+	To     time.Time
+
+	// After continues the selection right after the change the cursor points to.
+	// It should be used instead of Offset.
+	After *Cursor
+
+	// Limit is ignored if it's not positive.
+	Limit  int
+	Offset int
+}
+
+// NewChangesCursor returns a cursor pointing to the given change.
+func NewChangesCursor(c *PositionChange) *Cursor {
+	return &Cursor{
+		OrderBy: ChangesOrderBy,
+		Values:  []interface{}{int64(abs(c.Impact)), int64(abs(c.Delta)), c.URL, c.Keyword},
+	}
+}
+
+// GetChanges compares the latest versions of the domain's positions observed on or before
+// the given dates. Changes are ordered by ChangesOrderBy, positions ranked beyond
+// TrackedPositions at both dates are omitted.
+func (pr *PositionRepo) GetChanges(ctx context.Context, opts *GetChangesOpts) ([]*PositionChange, error) {
+	qb := pr.changesQueryBuilder(opts)
+	if opts.After != nil {
+		opts.After.seek(qb)
+	}
+
+	query := changesSQL() + fmt.Sprintf(selectChangesQuery, qb.whereSQL(), orderBySQL(ChangesOrderBy))
+	if opts.Limit > 0 {
+		query = fmt.Sprintf("%s LIMIT %s", query, qb.bind(opts.Limit))
+		if opts.After == nil {
+			query = fmt.Sprintf("%s OFFSET %s", query, qb.bind(opts.Offset))
+		}
+	}
+
+	rows, err := pr.conn.QueryContext(ctx, query, qb.args...)
+	if err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	changes := make([]*PositionChange, 0)
+	for rows.Next() {
+		c := &PositionChange{}
+		if err := rows.Scan(&c.Keyword,
+			&c.URL,
+			&c.Type,
+			&c.PreviousPosition,
+			&c.Position,
+			&c.Delta,
+			&c.Volume,
+			&c.Impact); err != nil {
+			pr.log.Error("failed to scan position change", zap.Error(err))
+
+			return nil, fmt.Errorf("failed to scan position change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return changes, nil
+}
+
+// CountChanges returns numbers of the domain's position changes between the given dates
+// by type, types without changes are included with zero.
+func (pr *PositionRepo) CountChanges(ctx context.Context, opts *GetChangesOpts) (map[string]int, error) {
+	qb := pr.changesQueryBuilder(opts)
+
+	rows, err := pr.conn.QueryContext(ctx, changesSQL()+countChangesQuery, qb.args...)
+	if err != nil {
+		pr.log.Error("failed to count position changes", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to count position changes: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{
+		ChangeNew:       0,
+		ChangeLost:      0,
+		ChangeImproved:  0,
+		ChangeDeclined:  0,
+		ChangeUnchanged: 0,
+	}
+	for rows.Next() {
+		var (
+			changeType string
+			count      int
+		)
+		if err := rows.Scan(&changeType, &count); err != nil {
+			pr.log.Error("failed to scan position changes count", zap.Error(err))
+
+			return nil, fmt.Errorf("failed to scan position changes count: %w", err)
+		}
+		counts[changeType] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		pr.log.Error("failed to count position changes", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to count position changes: %w", err)
+	}
+
+	return counts, nil
+}
+
+// changesQueryBuilder returns a builder holding arguments of changesCTE in their order.
+func (pr *PositionRepo) changesQueryBuilder(opts *GetChangesOpts) *queryBuilder {
+	qb := pr.queryBuilder()
+	qb.bind(opts.Domain)
+	qb.bind(startOfDay(opts.To).AddDate(0, 0, 1).Unix())
+	qb.bind(startOfDay(opts.From).AddDate(0, 0, 1).Unix())
+
+	return qb
+}
+
+func changesSQL() string {
+	return fmt.Sprintf(changesCTE, TrackedPositions, TrackedPositions+1)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/log"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetChangesTypes(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.CreateSchema(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// 2020-01-01 and 2020-01-10
+	const before, after = 1577836800, 1578614400
+	version := func(keyword string, position int, updated int64) testutils.FixturePosition {
+		return testutils.FixturePosition{Domain: "fidel.net", Keyword: keyword, URL: "https://fidel.net/" + keyword,
+			Position: position, Volume: 10, Updated: updated}
+	}
+	testutils.LoadFixture(t, b.DB, &testutils.Fixture{
+		Positions: []testutils.FixturePosition{
+			version("new", 5, after),
+			version("new-in-top", 100, after),
+			version("lost", 101, after),
+			version("improved", 3, after),
+			version("declined", 7, after),
+			version("unchanged", 3, before),
+			version("unranked", 150, after),
+		},
+		History: []testutils.FixturePosition{
+			version("new-in-top", 150, before),
+			version("lost", 7, before),
+			version("improved", 7, before),
+			version("declined", 3, before),
+			version("unranked", 120, before),
+		},
+	})
+
+	repo := NewPositionRepo(logger, b.DB)
+	opts := &GetChangesOpts{
+		Domain: "fidel.net",
+		From:   time.Unix(before, 0),
+		To:     time.Unix(after, 0),
+	}
+	got, err := repo.GetChanges(context.Background(), opts)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		keyword       string
+		expectedType  string
+		expectedDelta int
+	}{
+		{"new", ChangeNew, 96},
+		{"lost", ChangeLost, -94},
+		{"declined", ChangeDeclined, -4},
+		{"improved", ChangeImproved, 4},
+		{"new-in-top", ChangeNew, 1},
+		{"unchanged", ChangeUnchanged, 0},
+	}
+
+	// Ties are ordered by url, not ranked at both dates is omitted
+	assert.Len(t, got, len(tests))
+	for i, tt := range tests {
+		if i >= len(got) {
+			break
+		}
+		assert.Equal(t, tt.keyword, got[i].Keyword)
+		assert.Equal(t, tt.expectedType, got[i].Type, tt.keyword)
+		assert.Equal(t, tt.expectedDelta, got[i].Delta, tt.keyword)
+		assert.Equal(t, tt.expectedDelta*10, got[i].Impact, tt.keyword)
+	}
+
+	// Pages continued with a cursor follow each other
+	opts.Limit = 2
+	page, err := repo.GetChanges(context.Background(), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, got[:2], page)
+
+	opts.After = NewChangesCursor(page[1])
+	page, err = repo.GetChanges(context.Background(), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, got[2:4], page)
+
+	counts, err := repo.CountChanges(context.Background(), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		ChangeNew:       2,
+		ChangeLost:      1,
+		ChangeImproved:  1,
+		ChangeDeclined:  1,
+		ChangeUnchanged: 1,
+	}, counts)
+}
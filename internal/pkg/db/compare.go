@@ -0,0 +1,209 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Modes of domains comparison, the first of compared domains is the one others are compared with.
+const (
+	CompareModeAll     = "all"
+	CompareModeMissing = "missing"
+	CompareModeWeak    = "weak"
+	CompareModeShared  = "shared"
+)
+
+const (
+	selectComparedKeywordsQuery = `SELECT keyword, MAX(volume)
+		FROM positions
+		%s
+		GROUP BY keyword
+		%s
+`
+
+	countComparedKeywordsQuery = `SELECT COUNT(1) FROM (%s) AS compared`
+
+	selectDomainRanksQuery = `SELECT keyword, domain, url, position
+		FROM positions
+		%s
+		ORDER BY keyword, domain, position, url
+`
+)
+
+// DomainRank represents the best position of a domain for a keyword.
+type DomainRank struct {
+	Position int    `json:"position"`
+	URL      string `json:"url"`
+}
+
+// KeywordComparison represents positions of compared domains for a keyword, domains which
+// don't rank for it have nil positions.
+type KeywordComparison struct {
+	Keyword   string                 `json:"keyword"`
+	Volume    int                    `json:"volume"`
+	Positions map[string]*DomainRank `json:"positions"`
+}
+
+// CompareDomainsOpts contains parameters of domains comparison.
+type CompareDomainsOpts struct {
+	// Domains must be unique, the first one is compared with the rest of them.
+	Domains []string
+	Mode    string
+	Filter  *PositionsFilter
+
+	// Limit is ignored if it's not positive.
+	Limit  int
+	Offset int
+}
+
+// CompareDomains returns best positions of domains for keywords matching the mode ordered by keyword.
+// Positions not matching the filter are treated as if domains don't rank for them.
+func (pr *PositionRepo) CompareDomains(ctx context.Context, opts *CompareDomainsOpts) ([]*KeywordComparison, error) {
+	query, qb := pr.buildComparedKeywordsQuery(opts)
+	query += " ORDER BY keyword"
+	if opts.Limit > 0 {
+		query = fmt.Sprintf("%s LIMIT %s OFFSET %s", query, qb.bind(opts.Limit), qb.bind(opts.Offset))
+	}
+
+	rows, err := pr.conn.QueryContext(ctx, query, qb.args...)
+	if err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	comparisons := make([]*KeywordComparison, 0)
+	byKeyword := make(map[string]*KeywordComparison)
+	for rows.Next() {
+		c := &KeywordComparison{Positions: make(map[string]*DomainRank, len(opts.Domains))}
+		if err := rows.Scan(&c.Keyword, &c.Volume); err != nil {
+			pr.log.Error("failed to scan keyword", zap.Error(err))
+
+			return nil, fmt.Errorf("failed to scan keyword: %w", err)
+		}
+
+		for _, domain := range opts.Domains {
+			c.Positions[domain] = nil
+		}
+		comparisons = append(comparisons, c)
+		byKeyword[c.Keyword] = c
+	}
+	if err := rows.Err(); err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	if len(comparisons) == 0 {
+		return comparisons, nil
+	}
+
+	if err := pr.fillDomainRanks(ctx, opts, byKeyword); err != nil {
+		return nil, err
+	}
+
+	return comparisons, nil
+}
+
+// CountComparedKeywords returns a number of keywords matching the mode.
+func (pr *PositionRepo) CountComparedKeywords(ctx context.Context, opts *CompareDomainsOpts) (int, error) {
+	query, qb := pr.buildComparedKeywordsQuery(opts)
+
+	var count int
+	if err := pr.conn.QueryRowxContext(ctx, fmt.Sprintf(countComparedKeywordsQuery, query), qb.args...).
+		Scan(&count); err != nil {
+		pr.log.Error("failed to count compared keywords", zap.Error(err))
+
+		return -1, fmt.Errorf("failed to count compared keywords: %w", err)
+	}
+
+	return count, nil
+}
+
+// fillDomainRanks sets the best positions of domains for the given keywords.
+func (pr *PositionRepo) fillDomainRanks(ctx context.Context, opts *CompareDomainsOpts,
+	byKeyword map[string]*KeywordComparison) error {
+	keywords := make([]interface{}, 0, len(byKeyword))
+	for keyword := range byKeyword {
+		keywords = append(keywords, keyword)
+	}
+
+	qb := pr.comparedPositionsBuilder(opts)
+	qb.where("keyword IN ("+placeholders(len(keywords))+")", keywords...)
+
+	rows, err := pr.conn.QueryContext(ctx, fmt.Sprintf(selectDomainRanksQuery, qb.whereSQL()), qb.args...)
+	if err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			keyword, domain string
+			rank            = &DomainRank{}
+		)
+		if err := rows.Scan(&keyword, &domain, &rank.URL, &rank.Position); err != nil {
+			pr.log.Error("failed to scan domain rank", zap.Error(err))
+
+			return fmt.Errorf("failed to scan domain rank: %w", err)
+		}
+
+		// The best position of a domain goes first
+		if c := byKeyword[keyword]; c.Positions[domain] == nil {
+			c.Positions[domain] = rank
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return nil
+}
+
+// comparedPositionsBuilder returns a builder selecting positions of compared domains matching the filter.
+func (pr *PositionRepo) comparedPositionsBuilder(opts *CompareDomainsOpts) *queryBuilder {
+	domains := make([]interface{}, 0, len(opts.Domains))
+	for _, domain := range opts.Domains {
+		domains = append(domains, domain)
+	}
+
+	qb := pr.queryBuilder()
+	qb.where("domain IN ("+placeholders(len(domains))+")", domains...)
+	opts.Filter.apply(qb)
+
+	return qb
+}
+
+// buildComparedKeywordsQuery returns a query selecting keywords matching the mode and their volume.
+func (pr *PositionRepo) buildComparedKeywordsQuery(opts *CompareDomainsOpts) (string, *queryBuilder) {
+	qb := pr.comparedPositionsBuilder(opts)
+
+	var having string
+	switch opts.Mode {
+	case CompareModeMissing:
+		having = fmt.Sprintf("HAVING MIN(CASE WHEN domain = %s THEN position END) IS NULL",
+			qb.bind(opts.Domains[0]))
+	case CompareModeWeak:
+		own := qb.bind(opts.Domains[0])
+		having = fmt.Sprintf("HAVING MIN(CASE WHEN domain = %[1]s THEN position END) > "+
+			"MIN(CASE WHEN domain <> %[1]s THEN position END)", own)
+	case CompareModeShared:
+		having = fmt.Sprintf("HAVING COUNT(DISTINCT domain) = %s", qb.bind(len(opts.Domains)))
+	}
+
+	return fmt.Sprintf(selectComparedKeywordsQuery, qb.whereSQL(), having), qb
+}
+
+// placeholders returns a comma-separated list of n '?' to be used with queryBuilder.where.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
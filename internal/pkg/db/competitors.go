@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Competitor's best position is used if it ranks for a keyword with several urls,
+	// volume of a keyword is the one of the domain itself.
+	competitorsCTE = `WITH own AS (
+			SELECT keyword, MAX(volume) AS volume
+			FROM positions
+			WHERE domain = $1
+			GROUP BY keyword
+		), rivals AS (
+			SELECT domain, keyword, MIN(position) AS position
+			FROM positions
+			WHERE domain <> $1
+			GROUP BY domain, keyword
+		)
+`
+
+	selectCompetitorsQuery = competitorsCTE + `SELECT
+				rivals.domain,
+				COUNT(1),
+				AVG(rivals.position),
+				SUM(own.volume)
+		FROM rivals
+		JOIN own ON own.keyword = rivals.keyword
+		GROUP BY rivals.domain
+		ORDER BY COUNT(1) DESC, SUM(own.volume) DESC, rivals.domain ASC
+		LIMIT $2 OFFSET $3
+`
+
+	countCompetitorsQuery = competitorsCTE + `SELECT COUNT(DISTINCT rivals.domain)
+		FROM rivals
+		JOIN own ON own.keyword = rivals.keyword
+`
+
+	countKeywordsQuery = `SELECT COUNT(DISTINCT keyword) FROM positions WHERE domain = $1`
+)
+
+// Competitor represents a domain ranking for the same keywords as the given one.
+type Competitor struct {
+	Domain         string `json:"domain"`
+	SharedKeywords int    `json:"shared_keywords"`
+	// Overlap is a percentage of the given domain's keywords the competitor ranks for.
+	Overlap float64 `json:"overlap"`
+	// AvgPosition is competitor's average position on shared keywords.
+	AvgPosition float64 `json:"avg_position"`
+	// SharedVolume is a combined search volume of shared keywords.
+	SharedVolume int64 `json:"shared_volume"`
+}
+
+// GetCompetitorsOpts contains parameters of competitors selection.
+type GetCompetitorsOpts struct {
+	Domain string
+	Limit  int
+	Offset int
+}
+
+// GetCompetitors returns domains sharing keywords with the given one, the ones with the most
+// shared keywords go first.
+func (pr *PositionRepo) GetCompetitors(ctx context.Context, opts *GetCompetitorsOpts) ([]*Competitor, error) {
+	var keywords int
+	if err := pr.conn.QueryRowxContext(ctx, countKeywordsQuery, opts.Domain).Scan(&keywords); err != nil {
+		pr.log.Error("failed to count keywords", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to count keywords: %w", err)
+	}
+
+	rows, err := pr.conn.QueryContext(ctx, selectCompetitorsQuery, opts.Domain, opts.Limit, opts.Offset)
+	if err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	competitors := make([]*Competitor, 0)
+	for rows.Next() {
+		c := &Competitor{}
+		if err := rows.Scan(&c.Domain, &c.SharedKeywords, &c.AvgPosition, &c.SharedVolume); err != nil {
+			pr.log.Error("failed to scan competitor", zap.Error(err))
+
+			return nil, fmt.Errorf("failed to scan competitor: %w", err)
+		}
+
+		// Competitors are found only if the domain has keywords
+		c.Overlap = float64(c.SharedKeywords) * 100 / float64(keywords)
+		competitors = append(competitors, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return competitors, nil
+}
+
+// CountCompetitors returns a number of domains sharing keywords with the given one.
+func (pr *PositionRepo) CountCompetitors(ctx context.Context, domain string) (int, error) {
+	var count int
+	if err := pr.conn.QueryRowxContext(ctx, countCompetitorsQuery, domain).Scan(&count); err != nil {
+		pr.log.Error("failed to count competitors", zap.Error(err))
+
+		return -1, fmt.Errorf("failed to count competitors: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Cursor points to a position, selection can be continued right after it.
+type Cursor struct {
+	OrderBy string
+	Values  []interface{}
+}
+
+// cursorToken is a serialized form of the Cursor.
+type cursorToken struct {
+	OrderBy string        `json:"o"`
+	Values  []interface{} `json:"v"`
+}
+
+// NewCursor returns a cursor pointing to the given position of a selection ordered by orderBy.
+func NewCursor(orderBy string, p *Position) *Cursor {
+	orderBy = normalizeOrderBy(orderBy)
+
+	keys := sortKeys(orderBy)
+	values := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, p.sortValue(k.column))
+	}
+
+	return &Cursor{OrderBy: orderBy, Values: values}
+}
+
+// Encode returns an opaque token of the cursor.
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(cursorToken{OrderBy: c.OrderBy, Values: c.Values})
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token returned by Cursor.Encode and checks that it belongs to
+// a selection ordered by orderBy.
+func DecodeCursor(token, orderBy string) (*Cursor, error) {
+	orderBy = normalizeOrderBy(orderBy)
+
+	errInvalid := errors.New("cursor is invalid")
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errInvalid
+	}
+
+	var ct cursorToken
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&ct); err != nil {
+		return nil, errInvalid
+	}
+
+	if ct.OrderBy != orderBy {
+		return nil, fmt.Errorf("cursor can't be used with positions ordered by '%s'", orderBy)
+	}
+
+	keys := sortKeys(orderBy)
+	if len(ct.Values) != len(keys) {
+		return nil, errInvalid
+	}
+
+	for i, k := range keys {
+		if ct.Values[i], err = normalizeCursorValue(sortColumnKind(k.column), ct.Values[i]); err != nil {
+			return nil, errInvalid
+		}
+	}
+
+	return &Cursor{OrderBy: orderBy, Values: ct.Values}, nil
+}
+
+// normalizeCursorValue converts a decoded JSON value to the type of the column.
+func normalizeCursorValue(kind columnKind, v interface{}) (interface{}, error) {
+	switch kind {
+	case kindString:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case kindInt:
+		if n, ok := v.(json.Number); ok {
+			return n.Int64()
+		}
+	case kindFloat:
+		if n, ok := v.(json.Number); ok {
+			return n.Float64()
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected cursor value %v", v)
+}
+
+// seek adds a condition that selects positions following the cursor.
+func (c *Cursor) seek(qb *queryBuilder) {
+	keys := sortKeys(c.OrderBy)
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., '<' is used for keys in descending order
+	var (
+		alternatives []string
+		args         []interface{}
+	)
+	for i, k := range keys {
+		equals := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			equals = append(equals, columnSQL(keys[j].column)+" = ?")
+			args = append(args, c.Values[j])
+		}
+
+		operator := " > ?"
+		if k.desc {
+			operator = " < ?"
+		}
+		equals = append(equals, columnSQL(k.column)+operator)
+		args = append(args, c.Values[i])
+
+		alternatives = append(alternatives, "("+strings.Join(equals, " AND ")+")")
+	}
+
+	qb.where("("+strings.Join(alternatives, " OR ")+")", args...)
+}
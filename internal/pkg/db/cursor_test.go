@@ -0,0 +1,64 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorEncodeDecode(t *testing.T) {
+	p := &Position{
+		URL:       "http://ulmart.ru/test1",
+		Keyword:   "test1",
+		Volume:    43,
+		CPC:       3.22,
+		updatedAt: 1495248847,
+	}
+
+	for _, orderBy := range []string{"", "volume", "cpc", "updated", "url", "keyword"} {
+		cursor := NewCursor(orderBy, p)
+
+		got, err := DecodeCursor(cursor.Encode(), orderBy)
+		assert.NoError(t, err, orderBy)
+		assert.Equal(t, cursor, got, orderBy)
+	}
+
+	assert.Equal(t, []interface{}{int64(43), "http://ulmart.ru/test1", "test1"}, NewCursor("", p).Values)
+	assert.Equal(t, []interface{}{"http://ulmart.ru/test1", "test1"}, NewCursor("url", p).Values)
+	assert.Equal(t, []interface{}{"test1", "http://ulmart.ru/test1"}, NewCursor("keyword", p).Values)
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	token := NewCursor("cpc", &Position{}).Encode()
+
+	_, err := DecodeCursor(token, "volume")
+	assert.EqualError(t, err, "cursor can't be used with positions ordered by 'volume'")
+
+	_, err = DecodeCursor("not a cursor", "volume")
+	assert.EqualError(t, err, "cursor is invalid")
+
+	// Sort value of a wrong type
+	_, err = DecodeCursor((&Cursor{OrderBy: "volume", Values: []interface{}{"43", "url", "keyword"}}).Encode(), "volume")
+	assert.EqualError(t, err, "cursor is invalid")
+}
+
+func TestCursorSeek(t *testing.T) {
+	qb := &queryBuilder{}
+	(&Cursor{OrderBy: "volume", Values: []interface{}{int64(43), "http://ulmart.ru/test1", "test1"}}).seek(qb)
+
+	assert.Equal(t, "WHERE ((volume > $1) OR (volume = $2 AND url > $3) OR (volume = $4 AND url = $5 AND keyword > $6))",
+		qb.whereSQL())
+	assert.Equal(t, []interface{}{
+		int64(43),
+		int64(43), "http://ulmart.ru/test1",
+		int64(43), "http://ulmart.ru/test1", "test1",
+	}, qb.args)
+}
+
+func TestCursorSeekDesc(t *testing.T) {
+	qb := &queryBuilder{}
+	(&Cursor{OrderBy: "-volume,url", Values: []interface{}{int64(43), "http://ulmart.ru/test1", "test1"}}).seek(qb)
+
+	assert.Equal(t, "WHERE ((volume < $1) OR (volume = $2 AND url > $3) OR (volume = $4 AND url = $5 AND keyword > $6))",
+		qb.whereSQL())
+}
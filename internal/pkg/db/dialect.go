@@ -0,0 +1,71 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dialect contains parts of queries which differ between SQL databases. Queries of the
+// package are written so that the rest of SQL is understood by all of them, e.g. '$N'
+// placeholders are supported by both SQLite and PostgreSQL.
+type dialect struct {
+	name string
+
+	// date returns an expression formatting a unix timestamp as a date in UpdatedLayout.
+	date func(timestamp string) string
+	// like is an operator of case-insensitive pattern matching.
+	like string
+
+	tableExistsQuery string
+
+	// searchTable is a table of full-text index which may be missing, search is always
+	// available if it's empty.
+	searchTable string
+	// matchExpression converts search terms to an expression of the full-text query.
+	matchExpression func(terms []searchTerm) string
+	// searchPositionsQuery and countSearchResultsQuery receive the expression as the first
+	// argument, they're formatted with searchQueryColumns.
+	searchPositionsQuery    string
+	countSearchResultsQuery string
+}
+
+var sqliteDialect = &dialect{
+	name: "sqlite",
+	date: func(timestamp string) string {
+		return "date(" + timestamp + ", 'unixepoch')"
+	},
+	// LIKE of SQLite is case-insensitive for ASCII characters
+	like: "LIKE",
+
+	tableExistsQuery: `SELECT COUNT(1) FROM sqlite_master WHERE type = 'table' AND name = $1`,
+
+	searchTable:             "positions_fts",
+	matchExpression:         fts5MatchExpression,
+	searchPositionsQuery:    fts5SearchPositionsQuery,
+	countSearchResultsQuery: fts5CountSearchResultsQuery,
+}
+
+var postgresDialect = &dialect{
+	name: "postgres",
+	date: func(timestamp string) string {
+		return "to_char(to_timestamp(" + timestamp + ") AT TIME ZONE 'UTC', 'YYYY-MM-DD')"
+	},
+	like: "ILIKE",
+
+	tableExistsQuery: `SELECT COUNT(1) FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name = $1`,
+
+	matchExpression:         tsqueryMatchExpression,
+	searchPositionsQuery:    tsquerySearchPositionsQuery,
+	countSearchResultsQuery: tsqueryCountSearchResultsQuery,
+}
+
+// dialectOf returns a dialect of the connection's driver, SQLite is assumed for unknown drivers.
+func dialectOf(conn *sqlx.DB) *dialect {
+	if strings.HasPrefix(conn.DriverName(), "postgres") {
+		return postgresDialect
+	}
+
+	return sqliteDialect
+}
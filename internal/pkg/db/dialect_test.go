@@ -0,0 +1,26 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialectOf(t *testing.T) {
+	assert.Equal(t, sqliteDialect, dialectOf(sqlx.NewDb(nil, "sqlite3")))
+	assert.Equal(t, postgresDialect, dialectOf(sqlx.NewDb(nil, "postgres")))
+}
+
+func TestDialectDate(t *testing.T) {
+	assert.Equal(t, "date(MIN(updated), 'unixepoch')", sqliteDialect.date("MIN(updated)"))
+	assert.Equal(t, "to_char(to_timestamp(updated) AT TIME ZONE 'UTC', 'YYYY-MM-DD')", postgresDialect.date("updated"))
+}
+
+func TestDialectLike(t *testing.T) {
+	qb := (&PositionRepo{dialect: postgresDialect}).queryBuilder()
+	(&PositionsFilter{KeywordPrefix: "Shoe"}).apply(qb)
+
+	assert.Equal(t, "WHERE keyword ILIKE $1 ESCAPE '\\'", qb.whereSQL())
+	assert.Equal(t, []interface{}{"Shoe%"}, qb.args)
+}
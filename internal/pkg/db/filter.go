@@ -0,0 +1,83 @@
+package db
+
+import "time"
+
+// PositionsFilter contains optional conditions to select positions by.
+// Nil bounds and empty strings are ignored.
+type PositionsFilter struct {
+	MinPosition *int
+	MaxPosition *int
+	MinVolume   *int
+	MaxVolume   *int
+	MinResults  *int
+	MaxResults  *int
+	MinCPC      *float64
+	MaxCPC      *float64
+
+	// UpdatedFrom and UpdatedTo are inclusive dates.
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+
+	KeywordPrefix   string
+	KeywordContains string
+	URLPrefix       string
+	URLContains     string
+}
+
+// apply adds conditions of the filter to the query.
+func (f *PositionsFilter) apply(qb *queryBuilder) {
+	if f == nil {
+		return
+	}
+
+	intBounds := []struct {
+		condition string
+		bound     *int
+	}{
+		{"position >= ?", f.MinPosition},
+		{"position <= ?", f.MaxPosition},
+		{"volume >= ?", f.MinVolume},
+		{"volume <= ?", f.MaxVolume},
+		{"results >= ?", f.MinResults},
+		{"results <= ?", f.MaxResults},
+	}
+	for _, b := range intBounds {
+		if b.bound != nil {
+			qb.where(b.condition, *b.bound)
+		}
+	}
+
+	if f.MinCPC != nil {
+		qb.where("cpc >= ?", *f.MinCPC)
+	}
+	if f.MaxCPC != nil {
+		qb.where("cpc <= ?", *f.MaxCPC)
+	}
+
+	// 'updated' is stored as a unix timestamp
+	if f.UpdatedFrom != nil {
+		qb.where("updated >= ?", startOfDay(*f.UpdatedFrom).Unix())
+	}
+	if f.UpdatedTo != nil {
+		qb.where("updated < ?", startOfDay(*f.UpdatedTo).AddDate(0, 0, 1).Unix())
+	}
+
+	if f.KeywordPrefix != "" {
+		qb.whereLike("keyword", "", f.KeywordPrefix, "%")
+	}
+	if f.KeywordContains != "" {
+		qb.whereLike("keyword", "%", f.KeywordContains, "%")
+	}
+	if f.URLPrefix != "" {
+		qb.whereLike("url", "", f.URLPrefix, "%")
+	}
+	if f.URLContains != "" {
+		qb.whereLike("url", "%", f.URLContains, "%")
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
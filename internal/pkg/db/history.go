@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	// recordPreviousVersionQuery keeps the current version of a position in the history before it's
+	// overwritten, positions written before the history existed aren't lost this way.
+	recordPreviousVersionQuery = `INSERT INTO positions_history (domain, url, keyword, position, volume, results, cpc, updated)
+		SELECT domain, url, keyword, position, volume, results, cpc, CAST(updated AS BIGINT)
+		FROM positions
+		WHERE domain = $1 AND url = $2 AND keyword = $3
+		ON CONFLICT (domain, url, keyword, updated) DO NOTHING
+`
+
+	recordVersionQuery = `INSERT INTO positions_history (domain, url, keyword, position, volume, results, cpc, updated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (domain, url, keyword, updated) DO UPDATE SET
+				position = excluded.position,
+				volume = excluded.volume,
+				results = excluded.results,
+				cpc = excluded.cpc
+`
+
+	// selectHistoryQuery includes current positions, so the latest version is returned even if
+	// the position hasn't been updated since the history was introduced.
+	selectHistoryQuery = `SELECT
+				keyword,
+				url,
+				position,
+				volume,
+				results,
+				cpc,
+				%[1]s
+		FROM (
+			SELECT keyword, url, position, volume, results, cpc, updated FROM positions_history %[2]s
+			UNION
+			SELECT keyword, url, position, volume, results, cpc, CAST(updated AS BIGINT) FROM positions %[2]s
+		) AS versions
+		ORDER BY keyword, url, updated
+`
+)
+
+// HistoryPoint represents a version of a position observed at the given date.
+type HistoryPoint struct {
+	Position int     `json:"position"`
+	Volume   int     `json:"volume"`
+	Results  int     `json:"results"`
+	CPC      float64 `json:"cpc"`
+	Updated  string  `json:"updated"`
+}
+
+// PositionHistory represents a time series of a single keyword's position of an url.
+type PositionHistory struct {
+	Keyword string          `json:"keyword"`
+	URL     string          `json:"url"`
+	Points  []*HistoryPoint `json:"points"`
+}
+
+// GetHistoryOpts contains parameters of history selection, empty Keyword and URL match any.
+type GetHistoryOpts struct {
+	Domain  string
+	Keyword string
+	URL     string
+
+	// From and To are inclusive dates.
+	From *time.Time
+	To   *time.Time
+}
+
+// GetHistory returns time series of the domain's positions ordered by keyword, url and date.
+func (pr *PositionRepo) GetHistory(ctx context.Context, opts *GetHistoryOpts) ([]*PositionHistory, error) {
+	qb := pr.queryBuilder()
+	qb.where("domain = ?", opts.Domain)
+	if opts.Keyword != "" {
+		qb.where("keyword = ?", opts.Keyword)
+	}
+	if opts.URL != "" {
+		qb.where("url = ?", opts.URL)
+	}
+	if opts.From != nil {
+		qb.where("updated >= ?", startOfDay(*opts.From).Unix())
+	}
+	if opts.To != nil {
+		qb.where("updated < ?", startOfDay(*opts.To).AddDate(0, 0, 1).Unix())
+	}
+
+	// Both parts of the union refer to the same placeholders
+	rows, err := pr.conn.QueryContext(ctx, fmt.Sprintf(selectHistoryQuery, pr.dialect.date("updated"), qb.whereSQL()), qb.args...)
+	if err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	history := make([]*PositionHistory, 0)
+	var last *PositionHistory
+	for rows.Next() {
+		var (
+			keyword, url string
+			point        = &HistoryPoint{}
+		)
+		if err := rows.Scan(&keyword,
+			&url,
+			&point.Position,
+			&point.Volume,
+			&point.Results,
+			&point.CPC,
+			&point.Updated); err != nil {
+			pr.log.Error("failed to scan history point", zap.Error(err))
+
+			return nil, fmt.Errorf("failed to scan history point: %w", err)
+		}
+
+		if last == nil || last.Keyword != keyword || last.URL != url {
+			last = &PositionHistory{Keyword: keyword, URL: url}
+			history = append(history, last)
+		}
+		last.Points = append(last.Points, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return history, nil
+}
+
+// recordHistory adds a new version of the position to the history keeping the one it replaces.
+func (pr *PositionRepo) recordHistory(ctx context.Context, tx *sqlx.Tx, domain string, p *Position, updated int64) error {
+	if _, err := tx.ExecContext(ctx, recordPreviousVersionQuery, domain, p.URL, p.Keyword); err != nil {
+		pr.log.Error("failed to record previous position version", zap.Error(err))
+
+		return fmt.Errorf("failed to record previous position version: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, recordVersionQuery,
+		domain,
+		p.URL,
+		p.Keyword,
+		p.Position,
+		p.Volume,
+		p.Results,
+		p.CPC,
+		updated); err != nil {
+		pr.log.Error("failed to record position version", zap.Error(err))
+
+		return fmt.Errorf("failed to record position version: %w", err)
+	}
+
+	return nil
+}
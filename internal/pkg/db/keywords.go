@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Metrics of a keyword are taken from its latest observed position.
+	getKeywordMetricsQuery = `SELECT volume, cpc, results, %[1]s
+		FROM positions
+		WHERE keyword = $1
+		ORDER BY updated DESC, domain ASC, url ASC
+		LIMIT 1
+`
+
+	selectKeywordRankingsQuery = `SELECT domain, url, position, %[1]s
+		FROM positions
+		WHERE keyword = $1
+		ORDER BY position ASC, domain ASC, url ASC
+`
+)
+
+// ErrKeywordNotFound is returned if no domain ranks for a keyword.
+var ErrKeywordNotFound = errors.New("keyword not found")
+
+// KeywordRanking represents a domain's url ranking for a keyword.
+type KeywordRanking struct {
+	Domain   string `json:"domain"`
+	URL      string `json:"url"`
+	Position int    `json:"position"`
+	Updated  string `json:"updated"`
+}
+
+// Keyword represents a keyword's metrics and domains ranking for it.
+type Keyword struct {
+	Keyword  string            `json:"keyword"`
+	Volume   int               `json:"volume"`
+	CPC      float64           `json:"cpc"`
+	Results  int               `json:"results"`
+	Updated  string            `json:"updated"`
+	Rankings []*KeywordRanking `json:"rankings"`
+}
+
+// GetKeyword returns the keyword's metrics and all domains ranking for it ordered by position.
+func (pr *PositionRepo) GetKeyword(ctx context.Context, keyword string) (*Keyword, error) {
+	k := &Keyword{Keyword: keyword}
+	if err := pr.conn.QueryRowxContext(ctx, fmt.Sprintf(getKeywordMetricsQuery, pr.dialect.date("updated")), keyword).
+		Scan(&k.Volume, &k.CPC, &k.Results, &k.Updated); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrKeywordNotFound
+		}
+		pr.log.Error("failed to get keyword metrics", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to get keyword metrics: %w", err)
+	}
+
+	rows, err := pr.conn.QueryContext(ctx, fmt.Sprintf(selectKeywordRankingsQuery, pr.dialect.date("updated")), keyword)
+	if err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	k.Rankings = make([]*KeywordRanking, 0)
+	for rows.Next() {
+		r := &KeywordRanking{}
+		if err := rows.Scan(&r.Domain, &r.URL, &r.Position, &r.Updated); err != nil {
+			pr.log.Error("failed to scan keyword ranking", zap.Error(err))
+
+			return nil, fmt.Errorf("failed to scan keyword ranking: %w", err)
+		}
+		k.Rankings = append(k.Rankings, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return k, nil
+}
@@ -5,28 +5,77 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
 const (
 	getSummaryQuery = `SELECT COUNT(1) FROM positions WHERE domain = $1`
 
-	getPositionsQuery = `SELECT
+	getDomainSummaryQuery = `SELECT
+				COUNT(1),
+				COUNT(DISTINCT keyword),
+				COUNT(DISTINCT url),
+				COALESCE(SUM(CASE WHEN position <= 3 THEN 1 ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN position <= 10 THEN 1 ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN position <= 100 THEN 1 ELSE 0 END), 0),
+				COALESCE(AVG(position), 0),
+				COALESCE(SUM(volume), 0),
+				COALESCE(SUM(volume * cpc), 0),
+				COALESCE(ROUND(SUM(%[1]s), 2), 0),
+				COALESCE(ROUND(SUM(%[2]s), 2), 0),
+				COALESCE(%[3]s, ''),
+				COALESCE(%[4]s, '')
+		FROM positions
+		WHERE domain = $1
+`
+
+	// getMedianPositionQuery averages one or two middle positions depending on parity of their count.
+	getMedianPositionQuery = `SELECT COALESCE(AVG(position), 0) FROM (
+			SELECT position
+			FROM positions
+			WHERE domain = $1
+			ORDER BY position
+			LIMIT $2 OFFSET $3
+		) AS middle
+`
+
+	countPositionsQuery = `SELECT COUNT(1) FROM positions %s`
+
+	selectPositionsQuery = `SELECT
 				keyword,
 				position,
 				url,
 				volume,
 				results,
 				cpc,
-				date(updated, 'unixepoch')
+				%[1]s,
+				CAST(updated AS BIGINT),
+				%[2]s,
+				%[3]s
 		FROM positions
-		WHERE domain = $1
-		ORDER BY %s ASC
-		LIMIT $2 OFFSET $3
+		%[4]s
+		ORDER BY %[5]s
+`
+
+	positionExistsQuery = `SELECT COUNT(1) FROM positions WHERE domain = $1 AND url = $2 AND keyword = $3`
+
+	upsertPositionQuery = `INSERT INTO positions (keyword, position, domain, url, volume, results, cpc, updated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (domain, url, keyword) DO UPDATE SET
+				position = excluded.position,
+				volume = excluded.volume,
+				results = excluded.results,
+				cpc = excluded.cpc,
+				updated = excluded.updated
 `
 )
 
+// UpdatedLayout is a layout of the 'updated' field of a position.
+const UpdatedLayout = "2006-01-02"
+
 // Position represents a single domain's position.
 type Position struct {
 	URL      string  `db:"url" json:"url"`
@@ -36,12 +85,79 @@ type Position struct {
 	Results  int     `db:"results" json:"results"`
 	CPC      float64 `db:"cpc" json:"cpc"`
 	Updated  string  `db:"updated" json:"updated"`
+
+	// Traffic is estimated by CTR of the position, TrafficValue is the traffic multiplied by CPC.
+	// Both are computed on read.
+	Traffic      float64 `db:"traffic" json:"traffic"`
+	TrafficValue float64 `db:"traffic_value" json:"traffic_value"`
+
+	// updatedAt is a raw value of 'updated' column positions are ordered by.
+	updatedAt int64
 }
 
-// DomainSummary represents a total number of positions for domain.
+// Validate checks that the position can be written to the storage.
+func (p *Position) Validate() error {
+	switch {
+	case p.URL == "":
+		return errors.New("url is required")
+	case p.Keyword == "":
+		return errors.New("keyword is required")
+	case p.Position < 1:
+		return errors.New("position must be greater than 0")
+	case p.Volume < 0:
+		return errors.New("volume must not be negative")
+	case p.Results < 0:
+		return errors.New("results must not be negative")
+	case p.CPC < 0:
+		return errors.New("cpc must not be negative")
+	}
+
+	if _, err := time.Parse(UpdatedLayout, p.Updated); err != nil {
+		return fmt.Errorf("updated must be a date in %s format", UpdatedLayout)
+	}
+
+	return nil
+}
+
+// DomainPositions represents a bunch of positions of a single domain.
+type DomainPositions struct {
+	Domain    string
+	Positions []*Position
+}
+
+// UpsertResult represents a number of inserted and updated positions.
+type UpsertResult struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+}
+
+// DomainSummary represents aggregated statistics of domain's positions.
 type DomainSummary struct {
 	Domain         string
 	PositionsCount int
+
+	DistinctKeywords int
+	DistinctURLs     int
+
+	// Top3, Top10 and Top100 are numbers of positions ranked within the top.
+	Top3   int
+	Top10  int
+	Top100 int
+
+	AvgPosition    float64
+	MedianPosition float64
+
+	TotalVolume int64
+	// TrafficValue is a sum of volume multiplied by CPC of all positions.
+	TrafficValue float64
+	// EstimatedTraffic and EstimatedTrafficValue are sums of estimated traffic of all positions
+	// and its value.
+	EstimatedTraffic      float64
+	EstimatedTrafficValue float64
+
+	// FirstUpdated and LastUpdated are empty if domain has no positions.
+	FirstUpdated string
+	LastUpdated  string
 }
 
 // GetSummary returns a total number of positions for the given domain.
@@ -67,49 +183,260 @@ func (pr *PositionRepo) GetSummary(ctx context.Context, domain string) (int, err
 	return positionsCount, nil
 }
 
-// GetPositions returns a slice of positions for the given domain.
-func (pr *PositionRepo) GetPositions(ctx context.Context, domain, orderBy string, limit, offset int) ([]*Position, error) {
-	// Set default order in case if empty is given
-	if orderBy == "" {
-		orderBy = "volume"
+// GetDomainSummary returns aggregated statistics of the given domain's positions.
+func (pr *PositionRepo) GetDomainSummary(ctx context.Context, domain string) (*DomainSummary, error) {
+	summary := &DomainSummary{Domain: domain}
+	if err := pr.conn.QueryRowxContext(ctx, pr.buildDomainSummaryQuery(), domain).Scan(
+		&summary.PositionsCount,
+		&summary.DistinctKeywords,
+		&summary.DistinctURLs,
+		&summary.Top3,
+		&summary.Top10,
+		&summary.Top100,
+		&summary.AvgPosition,
+		&summary.TotalVolume,
+		&summary.TrafficValue,
+		&summary.EstimatedTraffic,
+		&summary.EstimatedTrafficValue,
+		&summary.FirstUpdated,
+		&summary.LastUpdated); err != nil {
+		pr.log.Error("failed to get domain summary", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to get domain summary: %w", err)
 	}
 
-	rows, err := pr.conn.QueryContext(ctx,
-		fmt.Sprintf(getPositionsQuery, orderBy),
-		domain,
-		limit,
-		offset,
-	)
+	if summary.PositionsCount == 0 {
+		return summary, nil
+	}
+
+	limit := 2 - summary.PositionsCount%2
+	offset := (summary.PositionsCount - 1) / 2
+	if err := pr.conn.QueryRowxContext(ctx, getMedianPositionQuery, domain, limit, offset).
+		Scan(&summary.MedianPosition); err != nil {
+		pr.log.Error("failed to get median position", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to get median position: %w", err)
+	}
+
+	return summary, nil
+}
+
+// CountPositions returns a number of the domain's positions matching the filter.
+func (pr *PositionRepo) CountPositions(ctx context.Context, domain string, filter *PositionsFilter) (int, error) {
+	qb := pr.queryBuilder()
+	qb.where("domain = ?", domain)
+	filter.apply(qb)
+
+	var count int
+	if err := pr.conn.QueryRowxContext(ctx, fmt.Sprintf(countPositionsQuery, qb.whereSQL()), qb.args...).Scan(&count); err != nil {
+		pr.log.Error("failed to count positions", zap.Error(err))
+
+		return -1, fmt.Errorf("failed to count positions: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetPositionsOpts contains parameters of positions selection.
+type GetPositionsOpts struct {
+	Domain  string
+	OrderBy string
+	Filter  *PositionsFilter
+
+	// After continues the selection right after the position the cursor points to.
+	// It should be used instead of Offset.
+	After *Cursor
+
+	// Limit is ignored if it's not positive.
+	Limit  int
+	Offset int
+}
+
+// GetPositions returns a slice of positions for the given domain matching the filter.
+func (pr *PositionRepo) GetPositions(ctx context.Context, opts *GetPositionsOpts) ([]*Position, error) {
+	positions := make([]*Position, 0)
+
+	err := pr.queryPositions(ctx, opts, func(p *Position) error {
+		positions = append(positions, p)
+
+		return nil
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return positions, nil
+}
+
+// StreamPositions calls fn for every position of the given domain matching the filter as they are read
+// from the cursor, so the whole result is never kept in memory. Iteration stops at the first error returned
+// by fn. The position is reused between calls, fn must not retain it.
+func (pr *PositionRepo) StreamPositions(ctx context.Context, opts *GetPositionsOpts, fn func(p *Position) error) error {
+	return pr.queryPositions(ctx, opts, fn, true)
+}
+
+func (pr *PositionRepo) queryPositions(ctx context.Context, opts *GetPositionsOpts, fn func(p *Position) error,
+	reuse bool) error {
+	query, qb := pr.buildPositionsQuery(opts)
+
+	rows, err := pr.conn.QueryContext(ctx, query, qb.args...)
 	if err != nil {
 		pr.log.Error("failed to execute query", zap.Error(err))
 
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+		return fmt.Errorf("failed to execute query: %w", err)
 	}
 	defer rows.Close()
 
-	positions := make([]*Position, 0, limit)
-
+	p := &Position{}
 	for rows.Next() {
-		p := &Position{}
+		if !reuse {
+			p = &Position{}
+		}
 		if err := rows.Scan(&p.Keyword,
 			&p.Position,
 			&p.URL,
 			&p.Volume,
 			&p.Results,
 			&p.CPC,
-			&p.Updated); err != nil {
+			&p.Updated,
+			&p.updatedAt,
+			&p.Traffic,
+			&p.TrafficValue); err != nil {
 			pr.log.Error("failed to scan position", zap.Error(err))
 
-			return nil, fmt.Errorf("failed to scan position: %w", err)
+			return fmt.Errorf("failed to scan position: %w", err)
+		}
+
+		if err := fn(p); err != nil {
+			return err
 		}
-		positions = append(positions, p)
 	}
 
 	if err := rows.Err(); err != nil {
 		pr.log.Error("failed to execute query", zap.Error(err))
 
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+		return fmt.Errorf("failed to execute query: %w", err)
 	}
 
-	return positions, nil
+	return nil
+}
+
+// buildDomainSummaryQuery returns a query aggregating positions of a domain.
+func (pr *PositionRepo) buildDomainSummaryQuery() string {
+	return fmt.Sprintf(getDomainSummaryQuery, trafficSQL(), trafficValueSQL(),
+		pr.dialect.date("MIN(updated)"), pr.dialect.date("MAX(updated)"))
+}
+
+// buildPositionsQuery returns a query selecting positions according to the options
+// along with the builder holding its arguments.
+func (pr *PositionRepo) buildPositionsQuery(opts *GetPositionsOpts) (string, *queryBuilder) {
+	qb := pr.queryBuilder()
+	qb.where("domain = ?", opts.Domain)
+	opts.Filter.apply(qb)
+	if opts.After != nil {
+		opts.After.seek(qb)
+	}
+
+	// Default order is used in case if empty is given, tie-breakers make pagination deterministic
+	query := fmt.Sprintf(selectPositionsQuery, pr.dialect.date("updated"), trafficSQL(), trafficValueSQL(),
+		qb.whereSQL(), orderBySQL(opts.OrderBy))
+
+	if opts.Limit > 0 {
+		query = fmt.Sprintf("%s LIMIT %s", query, qb.bind(opts.Limit))
+		if opts.After == nil {
+			query = fmt.Sprintf("%s OFFSET %s", query, qb.bind(opts.Offset))
+		}
+	}
+
+	return query, qb
+}
+
+// UpsertPositions inserts the given positions of the domain or updates existing ones
+// within a single transaction. Positions must be validated before the call.
+func (pr *PositionRepo) UpsertPositions(ctx context.Context, domain string, positions []*Position) (*UpsertResult, error) {
+	return pr.ImportPositions(ctx, []*DomainPositions{{Domain: domain, Positions: positions}}, false)
+}
+
+// ImportPositions inserts or updates positions of several domains within a single transaction.
+// In dry-run mode the transaction is rolled back, so the result only tells what would be written.
+// Positions must be validated before the call.
+func (pr *PositionRepo) ImportPositions(ctx context.Context, batch []*DomainPositions, dryRun bool) (*UpsertResult, error) {
+	tx, err := pr.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.log.Error("failed to begin transaction", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		// Rollback is a no-op if the transaction has been already committed.
+		_ = tx.Rollback()
+	}()
+
+	result := &UpsertResult{}
+	for _, dp := range batch {
+		for _, p := range dp.Positions {
+			inserted, err := pr.upsertPosition(ctx, tx, dp.Domain, p)
+			if err != nil {
+				return nil, err
+			}
+
+			if inserted {
+				result.Inserted++
+			} else {
+				result.Updated++
+			}
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	if err := pr.bumpVersions(ctx, tx, batch, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		pr.log.Error("failed to commit transaction", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// upsertPosition writes a single position within the given transaction and reports
+// whether it has been inserted or an existing one has been updated.
+func (pr *PositionRepo) upsertPosition(ctx context.Context, tx *sqlx.Tx, domain string, p *Position) (bool, error) {
+	updated, err := time.Parse(UpdatedLayout, p.Updated)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse updated date of '%s' keyword: %w", p.Keyword, err)
+	}
+
+	var count int
+	if err := tx.QueryRowxContext(ctx, positionExistsQuery, domain, p.URL, p.Keyword).Scan(&count); err != nil {
+		pr.log.Error("failed to check position existence", zap.Error(err))
+
+		return false, fmt.Errorf("failed to check position existence: %w", err)
+	}
+
+	if err := pr.recordHistory(ctx, tx, domain, p, updated.Unix()); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, upsertPositionQuery,
+		p.Keyword,
+		p.Position,
+		domain,
+		p.URL,
+		p.Volume,
+		p.Results,
+		p.CPC,
+		updated.Unix()); err != nil {
+		pr.log.Error("failed to upsert position", zap.Error(err))
+
+		return false, fmt.Errorf("failed to upsert position: %w", err)
+	}
+
+	return count == 0, nil
 }
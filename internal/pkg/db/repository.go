@@ -1,20 +1,61 @@
 package db
 
 import (
+	"context"
+
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
-// PositionRepo represents a data access layer to 'position' table.
+// Repository represents a storage of domains' positions.
+type Repository interface {
+	GetSummary(ctx context.Context, domain string) (int, error)
+	GetDomainSummary(ctx context.Context, domain string) (*DomainSummary, error)
+
+	CountPositions(ctx context.Context, domain string, filter *PositionsFilter) (int, error)
+	GetDatasetVersion(ctx context.Context, domain string) (*DatasetVersion, error)
+	GetPositions(ctx context.Context, opts *GetPositionsOpts) ([]*Position, error)
+	StreamPositions(ctx context.Context, opts *GetPositionsOpts, fn func(p *Position) error) error
+	UpsertPositions(ctx context.Context, domain string, positions []*Position) (*UpsertResult, error)
+	ImportPositions(ctx context.Context, batch []*DomainPositions, dryRun bool) (*UpsertResult, error)
+
+	GetHistory(ctx context.Context, opts *GetHistoryOpts) ([]*PositionHistory, error)
+	GetChanges(ctx context.Context, opts *GetChangesOpts) ([]*PositionChange, error)
+	CountChanges(ctx context.Context, opts *GetChangesOpts) (map[string]int, error)
+
+	GetCompetitors(ctx context.Context, opts *GetCompetitorsOpts) ([]*Competitor, error)
+	CountCompetitors(ctx context.Context, domain string) (int, error)
+	CompareDomains(ctx context.Context, opts *CompareDomainsOpts) ([]*KeywordComparison, error)
+	CountComparedKeywords(ctx context.Context, opts *CompareDomainsOpts) (int, error)
+
+	GetKeyword(ctx context.Context, keyword string) (*Keyword, error)
+	GetRollups(ctx context.Context, opts *GetRollupsOpts) ([]*Rollup, error)
+
+	SearchPositions(ctx context.Context, opts *SearchPositionsOpts) ([]*SearchResult, error)
+	CountSearchResults(ctx context.Context, query string) (int, error)
+}
+
+// PositionRepo represents a data access layer to 'position' table, it works with any
+// driver supported by backend.
 type PositionRepo struct {
-	conn *sqlx.DB
-	log  *zap.Logger
+	conn    *sqlx.DB
+	dialect *dialect
+	log     *zap.Logger
 }
 
-// NewPositionRepo returns new instance of PositionRepo.
+var _ Repository = (*PositionRepo)(nil)
+
+// NewPositionRepo returns new instance of PositionRepo, queries are built in the dialect
+// of the connection's driver.
 func NewPositionRepo(log *zap.Logger, conn *sqlx.DB) *PositionRepo {
 	return &PositionRepo{
-		conn: conn,
-		log:  log,
+		conn:    conn,
+		dialect: dialectOf(conn),
+		log:     log,
 	}
 }
+
+// queryBuilder returns a builder of conditions in the repository's dialect.
+func (pr *PositionRepo) queryBuilder() *queryBuilder {
+	return &queryBuilder{like: pr.dialect.like}
+}
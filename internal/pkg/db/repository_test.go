@@ -2,7 +2,9 @@ package db
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
 	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
@@ -12,11 +14,6 @@ import (
 )
 
 func TestGetSummary(t *testing.T) {
-	// Check acceptance test flag
-	if !testutils.IsAccTestEnabled(t) {
-		return
-	}
-
 	// Init global app configuration
 	testutils.InitTestConfig()
 
@@ -46,11 +43,6 @@ func TestGetSummary(t *testing.T) {
 }
 
 func TestGetPositions_DefaultOrder(t *testing.T) {
-	// Check acceptance test flag
-	if !testutils.IsAccTestEnabled(t) {
-		return
-	}
-
 	// Init global app configuration
 	testutils.InitTestConfig()
 
@@ -72,7 +64,10 @@ func TestGetPositions_DefaultOrder(t *testing.T) {
 
 	repo := NewPositionRepo(logger, b.DB)
 	// Query positions with default order by "volume"
-	got, err := repo.GetPositions(context.Background(), testutils.TestDomain, "", 1, 0)
+	got, err := repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain: testutils.TestDomain,
+		Limit:  1,
+	})
 	assert.NoError(t, err)
 	assert.NotNil(t, got)
 	assert.Len(t, got, 1)
@@ -80,19 +75,31 @@ func TestGetPositions_DefaultOrder(t *testing.T) {
 	assert.Equal(t, 43, got[0].Volume)
 
 	// Query next chunk with offset
-	got, err = repo.GetPositions(context.Background(), testutils.TestDomain, "", 1, 1)
+	got, err = repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain: testutils.TestDomain,
+		Limit:  1,
+		Offset: 1,
+	})
 	assert.NoError(t, err)
 	assert.NotNil(t, got)
 	assert.Len(t, got, 1)
 
 	// Query the last chunk with offset
-	got, err = repo.GetPositions(context.Background(), testutils.TestDomain, "", 1, 2)
+	got, err = repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain: testutils.TestDomain,
+		Limit:  1,
+		Offset: 2,
+	})
 	assert.NoError(t, err)
 	assert.NotNil(t, got)
 	assert.Len(t, got, 1)
 
 	// Check that nothing is left after offset 3
-	got, err = repo.GetPositions(context.Background(), testutils.TestDomain, "", 1, 3)
+	got, err = repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain: testutils.TestDomain,
+		Limit:  1,
+		Offset: 3,
+	})
 	assert.NoError(t, err)
 	assert.Empty(t, got)
 
@@ -100,11 +107,6 @@ func TestGetPositions_DefaultOrder(t *testing.T) {
 }
 
 func TestGetPositions_OrderByCPC(t *testing.T) {
-	// Check acceptance test flag
-	if !testutils.IsAccTestEnabled(t) {
-		return
-	}
-
 	// Init global app configuration
 	testutils.InitTestConfig()
 
@@ -126,25 +128,759 @@ func TestGetPositions_OrderByCPC(t *testing.T) {
 
 	repo := NewPositionRepo(logger, b.DB)
 	// Query positions with default order by "volume"
-	got, err := repo.GetPositions(context.Background(), testutils.TestDomain, "cpc", 1, 0)
+	got, err := repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain:  testutils.TestDomain,
+		OrderBy: "cpc",
+		Limit:   1,
+	})
 	assert.NoError(t, err)
 	assert.NotNil(t, got)
 	assert.Len(t, got, 1)
 
 	// Query next chunk with offset
-	got, err = repo.GetPositions(context.Background(), testutils.TestDomain, "cpc", 1, 1)
+	got, err = repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain:  testutils.TestDomain,
+		OrderBy: "cpc",
+		Limit:   1,
+		Offset:  1,
+	})
 	assert.NoError(t, err)
 	assert.NotNil(t, got)
 	assert.Len(t, got, 1)
 
 	// Query the last chunk with offset
-	got, err = repo.GetPositions(context.Background(), testutils.TestDomain, "cpc", 1, 2)
+	got, err = repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain:  testutils.TestDomain,
+		OrderBy: "cpc",
+		Limit:   1,
+		Offset:  2,
+	})
 	assert.NoError(t, err)
 	assert.NotNil(t, got)
 	assert.Len(t, got, 1)
 
 	// Check that nothing is left after offset 3
-	got, err = repo.GetPositions(context.Background(), testutils.TestDomain, "cpc", 1, 3)
+	got, err = repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain:  testutils.TestDomain,
+		OrderBy: "cpc",
+		Limit:   1,
+		Offset:  3,
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestUpsertPositions(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := NewPositionRepo(logger, b.DB)
+	got, err := repo.UpsertPositions(context.Background(), testutils.TestDomain, []*Position{
+		// Existing position with a new volume
+		{URL: "http://ulmart.ru/test1", Keyword: "test1", Position: 1, Volume: 10, Updated: "2017-05-21"},
+		// New position
+		{URL: "http://ulmart.ru/test5", Keyword: "test5", Position: 5, Volume: 20, Updated: "2017-05-21"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &UpsertResult{Inserted: 1, Updated: 1}, got)
+
+	count, err := repo.GetSummary(context.Background(), testutils.TestDomain)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	// Check that updated position has the lowest volume now
+	positions, err := repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain: testutils.TestDomain,
+		Limit:  1,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, positions, 1)
+	assert.Equal(t, "test1", positions[0].Keyword)
+	assert.Equal(t, 10, positions[0].Volume)
+	assert.Equal(t, "2017-05-21", positions[0].Updated)
+}
+
+func TestImportPositions_DryRun(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := NewPositionRepo(logger, b.DB)
+	got, err := repo.ImportPositions(context.Background(), []*DomainPositions{
+		{
+			Domain: testutils.TestDomain,
+			Positions: []*Position{
+				{URL: "http://ulmart.ru/test1", Keyword: "test1", Position: 1, Updated: "2017-05-21"},
+				{URL: "http://ulmart.ru/test5", Keyword: "test5", Position: 5, Updated: "2017-05-21"},
+			},
+		},
+		{
+			Domain: "ozon.ru",
+			Positions: []*Position{
+				{URL: "http://ozon.ru/test1", Keyword: "test1", Position: 3, Updated: "2017-05-21"},
+			},
+		},
+	}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, &UpsertResult{Inserted: 2, Updated: 1}, got)
+
+	// Check that nothing has been written
+	count, err := repo.GetSummary(context.Background(), testutils.TestDomain)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	count, err = repo.GetSummary(context.Background(), "ozon.ru")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestGetPositions_Filter(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	minVolume, maxPosition := 50, 2
+	updatedFrom := time.Date(2017, 5, 20, 0, 0, 0, 0, time.UTC)
+
+	repo := NewPositionRepo(logger, b.DB)
+	got, err := repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain: testutils.TestDomain,
+		Filter: &PositionsFilter{
+			MinVolume:     &minVolume,
+			MaxPosition:   &maxPosition,
+			UpdatedFrom:   &updatedFrom,
+			UpdatedTo:     &updatedFrom,
+			KeywordPrefix: "test",
+		},
+		Limit: 10,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "test2", got[0].Keyword)
+
+	// Wildcards are matched literally
+	got, err = repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain: testutils.TestDomain,
+		Filter: &PositionsFilter{URLContains: "ulmart.ru/test_"},
+		Limit:  10,
+	})
 	assert.NoError(t, err)
 	assert.Empty(t, got)
 }
+
+func TestGetPositions_Cursor(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	orders := []string{"-volume,position", "-keyword", "cpc,-updated"}
+	for orderBy := range sortColumns {
+		orders = append(orders, orderBy, descPrefix+orderBy)
+	}
+
+	repo := NewPositionRepo(logger, b.DB)
+	for _, orderBy := range orders {
+		expected, err := repo.GetPositions(context.Background(), &GetPositionsOpts{
+			Domain:  testutils.TestDomain,
+			OrderBy: orderBy,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, expected, 3)
+
+		// Walk through all positions one by one
+		var (
+			cursor *Cursor
+			got    []*Position
+		)
+		for {
+			page, err := repo.GetPositions(context.Background(), &GetPositionsOpts{
+				Domain:  testutils.TestDomain,
+				OrderBy: orderBy,
+				After:   cursor,
+				Limit:   1,
+			})
+			assert.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+			got = append(got, page...)
+			cursor = NewCursor(orderBy, page[0])
+		}
+
+		assert.Equal(t, expected, got, orderBy)
+	}
+}
+
+func TestGetPositions_OrderByDesc(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := NewPositionRepo(logger, b.DB)
+	got, err := repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain:  testutils.TestDomain,
+		OrderBy: "-volume",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, got, 3)
+	// Check that the highest volume goes first
+	assert.Equal(t, []int{76, 55, 43}, []int{got[0].Volume, got[1].Volume, got[2].Volume})
+
+	got, err = repo.GetPositions(context.Background(), &GetPositionsOpts{
+		Domain:  testutils.TestDomain,
+		OrderBy: "-traffic",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, got, 3)
+	// Better positions get more traffic despite lower volume
+	assert.Equal(t, []float64{13.59, 8.69, 7.6}, []float64{got[0].Traffic, got[1].Traffic, got[2].Traffic})
+	assert.Equal(t, []float64{43.75, 10.6, 16.87}, []float64{got[0].TrafficValue, got[1].TrafficValue, got[2].TrafficValue})
+}
+
+func TestGetDomainSummary(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := NewPositionRepo(logger, b.DB)
+	got, err := repo.GetDomainSummary(context.Background(), "non-ulmart.ru")
+	assert.NoError(t, err)
+	assert.Equal(t, &DomainSummary{
+		Domain:           "non-ulmart.ru",
+		PositionsCount:   2,
+		DistinctKeywords: 1,
+		DistinctURLs:     2,
+		Top3:             0,
+		Top10:            1,
+		Top100:           2,
+		AvgPosition:      9,
+		MedianPosition:   9,
+		TotalVolume:      97,
+		TrafficValue:     got.TrafficValue,
+		// Only the 7th position is within the default CTR curve
+		EstimatedTraffic:      got.EstimatedTraffic,
+		EstimatedTrafficValue: got.EstimatedTrafficValue,
+		FirstUpdated:          "2017-05-20",
+		LastUpdated:           "2017-05-20",
+	}, got)
+	assert.InDelta(t, 97*5.22, got.TrafficValue, 0.0001)
+	assert.InDelta(t, 0.96, got.EstimatedTraffic, 0.0001)
+	assert.InDelta(t, 5.01, got.EstimatedTrafficValue, 0.0001)
+
+	// Domain without positions
+	got, err = repo.GetDomainSummary(context.Background(), "ozon.ru")
+	assert.NoError(t, err)
+	assert.Equal(t, &DomainSummary{Domain: "ozon.ru"}, got)
+}
+
+func TestGetHistory(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := NewPositionRepo(logger, b.DB)
+
+	// Current position is the latest point
+	got, err := repo.GetHistory(context.Background(), &GetHistoryOpts{
+		Domain:  testutils.TestDomain,
+		Keyword: "test1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*PositionHistory{
+		{
+			Keyword: "test1",
+			URL:     "http://ulmart.ru/test1",
+			Points: []*HistoryPoint{
+				{Position: 5, Volume: 40, Results: 40000, CPC: 3.10, Updated: "2017-05-17"},
+				{Position: 3, Volume: 41, Results: 40000, CPC: 3.15, Updated: "2017-05-18"},
+				{Position: 1, Volume: 43, Results: 40000, CPC: 3.22, Updated: "2017-05-20"},
+			},
+		},
+	}, got)
+
+	// Overwritten version is kept
+	_, err = repo.UpsertPositions(context.Background(), testutils.TestDomain, []*Position{
+		{URL: "http://ulmart.ru/test2", Keyword: "test2", Position: 4, Volume: 50, Results: 100, CPC: 1, Updated: "2017-05-21"},
+	})
+	assert.NoError(t, err)
+
+	got, err = repo.GetHistory(context.Background(), &GetHistoryOpts{
+		Domain: testutils.TestDomain,
+		URL:    "http://ulmart.ru/test2",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*PositionHistory{
+		{
+			Keyword: "test2",
+			URL:     "http://ulmart.ru/test2",
+			Points: []*HistoryPoint{
+				{Position: 2, Volume: 55, Results: 40000, CPC: 1.22, Updated: "2017-05-20"},
+				{Position: 4, Volume: 50, Results: 100, CPC: 1, Updated: "2017-05-21"},
+			},
+		},
+	}, got)
+
+	// Dates are inclusive
+	from := time.Date(2017, 5, 18, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2017, 5, 20, 0, 0, 0, 0, time.UTC)
+	got, err = repo.GetHistory(context.Background(), &GetHistoryOpts{
+		Domain:  testutils.TestDomain,
+		Keyword: "test1",
+		From:    &from,
+		To:      &to,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Len(t, got[0].Points, 2)
+
+	// Unknown keyword
+	got, err = repo.GetHistory(context.Background(), &GetHistoryOpts{
+		Domain:  testutils.TestDomain,
+		Keyword: "unknown",
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestGetChanges(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := NewPositionRepo(logger, b.DB)
+	got, err := repo.GetChanges(context.Background(), &GetChangesOpts{
+		Domain: testutils.TestDomain,
+		From:   time.Date(2017, 5, 18, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2017, 5, 20, 0, 0, 0, 0, time.UTC),
+	})
+	assert.NoError(t, err)
+
+	one, two, three := 1, 2, 3
+	assert.Equal(t, []*PositionChange{
+		{Keyword: "test3", URL: "http://ulmart.ru/test3", Type: ChangeNew, Position: &three, Delta: 98, Volume: 76, Impact: 7448},
+		{Keyword: "test2", URL: "http://ulmart.ru/test2", Type: ChangeNew, Position: &two, Delta: 99, Volume: 55, Impact: 5445},
+		{Keyword: "test1", URL: "http://ulmart.ru/test1", Type: ChangeImproved, PreviousPosition: &three, Position: &one,
+			Delta: 2, Volume: 43, Impact: 86},
+	}, got)
+
+	// Nothing has changed since the last update
+	got, err = repo.GetChanges(context.Background(), &GetChangesOpts{
+		Domain: testutils.TestDomain,
+		From:   time.Date(2017, 5, 20, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2017, 5, 25, 0, 0, 0, 0, time.UTC),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, got, 3)
+	for _, c := range got {
+		assert.Equal(t, ChangeUnchanged, c.Type)
+	}
+}
+
+func TestGetCompetitors(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := NewPositionRepo(logger, b.DB)
+	got, err := repo.GetCompetitors(context.Background(), &GetCompetitorsOpts{
+		Domain: testutils.TestDomain,
+		Limit:  10,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "eldorado.ru", got[0].Domain)
+	assert.Equal(t, 2, got[0].SharedKeywords)
+	assert.InDelta(t, 66.67, got[0].Overlap, 0.01)
+	// Competitor's best position is taken for 'test2' keyword
+	assert.Equal(t, float64(6), got[0].AvgPosition)
+	assert.Equal(t, int64(98), got[0].SharedVolume)
+
+	count, err := repo.CountCompetitors(context.Background(), testutils.TestDomain)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Domain without shared keywords
+	got, err = repo.GetCompetitors(context.Background(), &GetCompetitorsOpts{
+		Domain: "non-ulmart.ru",
+		Limit:  10,
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestCompareDomains(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := NewPositionRepo(logger, b.DB)
+	domains := []string{"eldorado.ru", testutils.TestDomain}
+
+	got, err := repo.CompareDomains(context.Background(), &CompareDomainsOpts{
+		Domains: domains,
+		Mode:    CompareModeAll,
+		Limit:   2,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*KeywordComparison{
+		{
+			Keyword: "test1",
+			Volume:  47,
+			Positions: map[string]*DomainRank{
+				"eldorado.ru": {Position: 4, URL: "http://eldorado.ru/test1"},
+				"ulmart.ru":   {Position: 1, URL: "http://ulmart.ru/test1"},
+			},
+		},
+		{
+			Keyword: "test2",
+			Volume:  55,
+			Positions: map[string]*DomainRank{
+				"eldorado.ru": {Position: 8, URL: "http://eldorado.ru/test2"},
+				"ulmart.ru":   {Position: 2, URL: "http://ulmart.ru/test2"},
+			},
+		},
+	}, got)
+
+	keywordsOf := func(comparisons []*KeywordComparison) []string {
+		keywords := make([]string, 0, len(comparisons))
+		for _, c := range comparisons {
+			keywords = append(keywords, c.Keyword)
+		}
+
+		return keywords
+	}
+
+	maxPosition := 5
+	tests := []struct {
+		opts     *CompareDomainsOpts
+		expected []string
+	}{
+		{&CompareDomainsOpts{Domains: domains, Mode: CompareModeMissing}, []string{"test3"}},
+		{&CompareDomainsOpts{Domains: domains, Mode: CompareModeWeak}, []string{"test1", "test2"}},
+		{&CompareDomainsOpts{Domains: domains, Mode: CompareModeShared}, []string{"test1", "test2"}},
+		{&CompareDomainsOpts{Domains: []string{testutils.TestDomain, "eldorado.ru"}, Mode: CompareModeWeak}, []string{}},
+		{&CompareDomainsOpts{Domains: domains, Mode: CompareModeShared, Filter: &PositionsFilter{MaxPosition: &maxPosition}},
+			[]string{"test1"}},
+	}
+
+	for _, tt := range tests {
+		got, err := repo.CompareDomains(context.Background(), tt.opts)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, keywordsOf(got), tt.opts.Mode)
+
+		count, err := repo.CountComparedKeywords(context.Background(), tt.opts)
+		assert.NoError(t, err)
+		assert.Equal(t, len(tt.expected), count, tt.opts.Mode)
+	}
+
+	// Domain which doesn't rank for a keyword has no position
+	got, err = repo.CompareDomains(context.Background(), &CompareDomainsOpts{Domains: domains, Mode: CompareModeMissing})
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Nil(t, got[0].Positions["eldorado.ru"])
+}
+
+func TestGetKeyword(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := NewPositionRepo(logger, b.DB)
+	got, err := repo.GetKeyword(context.Background(), "test2")
+	assert.NoError(t, err)
+	assert.Equal(t, &Keyword{
+		Keyword: "test2",
+		Volume:  50,
+		CPC:     1.22,
+		Results: 40000,
+		Updated: "2017-05-20",
+		Rankings: []*KeywordRanking{
+			{Domain: "ulmart.ru", URL: "http://ulmart.ru/test2", Position: 2, Updated: "2017-05-20"},
+			{Domain: "eldorado.ru", URL: "http://eldorado.ru/test2", Position: 8, Updated: "2017-05-20"},
+			{Domain: "eldorado.ru", URL: "http://eldorado.ru/test2/more", Position: 12, Updated: "2017-05-20"},
+		},
+	}, got)
+
+	_, err = repo.GetKeyword(context.Background(), "unknown")
+	assert.Equal(t, ErrKeywordNotFound, err)
+}
+
+func TestGetRollups(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := NewPositionRepo(logger, b.DB)
+	got, err := repo.GetRollups(context.Background(), &GetRollupsOpts{
+		Domain: "eldorado.ru",
+		By:     RollupByPath,
+		Depth:  1,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, "eldorado.ru/test2", got[0].Group)
+	assert.Equal(t, 2, got[0].Count)
+	assert.Equal(t, float64(10), got[0].AvgPosition)
+	assert.Equal(t, int64(100), got[0].TotalVolume)
+	assert.Equal(t, "test2", got[0].TopKeyword)
+	assert.Equal(t, "eldorado.ru/test1", got[1].Group)
+	assert.Equal(t, 1, got[1].Count)
+
+	// Filter is applied before grouping
+	maxPosition := 10
+	got, err = repo.GetRollups(context.Background(), &GetRollupsOpts{
+		Domain: "eldorado.ru",
+		By:     RollupByHost,
+		Filter: &PositionsFilter{MaxPosition: &maxPosition},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "eldorado.ru", got[0].Group)
+	assert.Equal(t, 2, got[0].Count)
+	assert.Equal(t, float64(6), got[0].AvgPosition)
+	assert.Equal(t, int64(97), got[0].TotalVolume)
+	assert.Equal(t, "test2", got[0].TopKeyword)
+}
+
+func TestSearchPositions(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := NewPositionRepo(logger, b.DB)
+
+	got, err := repo.SearchPositions(context.Background(), &SearchPositionsOpts{Query: "test2", Limit: 10})
+	if errors.Is(err, ErrSearchUnavailable) {
+		t.Log("SQLite is built without FTS5, run tests with 'sqlite_fts5' tag")
+
+		return
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, []*SearchResult{
+		{Domain: "ulmart.ru", Position: Position{Keyword: "test2", Position: 2, URL: "http://ulmart.ru/test2",
+			Volume: 55, Results: 40000, CPC: 1.22, Updated: "2017-05-20", Traffic: 8.69, TrafficValue: 10.6}},
+		{Domain: "eldorado.ru", Position: Position{Keyword: "test2", Position: 8, URL: "http://eldorado.ru/test2",
+			Volume: 50, Results: 40000, CPC: 1.22, Updated: "2017-05-20", Traffic: 1.05, TrafficValue: 1.28}},
+		{Domain: "eldorado.ru", Position: Position{Keyword: "test2", Position: 12, URL: "http://eldorado.ru/test2/more",
+			Volume: 50, Results: 40000, CPC: 1.22, Updated: "2017-05-20"}},
+	}, got)
+
+	// Prefix search across keywords and urls
+	count, err := repo.CountSearchResults(context.Background(), "tes*")
+	assert.NoError(t, err)
+	assert.Equal(t, 8, count)
+
+	count, err = repo.CountSearchResults(context.Background(), "ulmart")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// New positions are indexed by triggers
+	_, err = repo.UpsertPositions(context.Background(), testutils.TestDomain, []*Position{
+		{URL: "http://ulmart.ru/shoes", Keyword: "running shoes", Position: 5, Volume: 20, Updated: "2017-05-21"},
+	})
+	assert.NoError(t, err)
+
+	got, err = repo.SearchPositions(context.Background(), &SearchPositionsOpts{Query: "running shoe*", Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "running shoes", got[0].Keyword)
+
+	_, err = repo.SearchPositions(context.Background(), &SearchPositionsOpts{Query: "*", Limit: 10})
+	assert.Equal(t, ErrEmptySearchQuery, err)
+}
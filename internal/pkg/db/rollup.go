@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Ways to group positions by their urls.
+const (
+	RollupByHost = "host"
+	RollupByPath = "path"
+)
+
+// Rollup represents aggregated positions of a group of urls.
+type Rollup struct {
+	// Group is a host or a host followed by path segments, e.g. "fidel.net/blog".
+	Group       string  `json:"group"`
+	Count       int     `json:"count"`
+	AvgPosition float64 `json:"avg_position"`
+	TotalVolume int64   `json:"total_volume"`
+	// TopKeyword is a keyword of the group with the highest volume.
+	TopKeyword string `json:"top_keyword"`
+
+	positionsSum int
+	topVolume    int
+	topPosition  int
+}
+
+// GetRollupsOpts contains parameters of positions grouping.
+type GetRollupsOpts struct {
+	Domain string
+	By     string
+	// Depth is a number of path segments urls are grouped by, it's used only by RollupByPath.
+	Depth  int
+	Filter *PositionsFilter
+}
+
+// GetRollups groups the domain's positions matching the filter by hosts or paths of their urls.
+// Groups with the highest total volume go first.
+func (pr *PositionRepo) GetRollups(ctx context.Context, opts *GetRollupsOpts) ([]*Rollup, error) {
+	groups := make(map[string]*Rollup)
+	err := pr.StreamPositions(ctx, &GetPositionsOpts{Domain: opts.Domain, Filter: opts.Filter}, func(p *Position) error {
+		key := rollupKey(p.URL, opts.By, opts.Depth)
+
+		g, ok := groups[key]
+		if !ok {
+			g = &Rollup{Group: key}
+			groups[key] = g
+		}
+		g.add(p)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rollups := make([]*Rollup, 0, len(groups))
+	for _, g := range groups {
+		g.AvgPosition = float64(g.positionsSum) / float64(g.Count)
+		rollups = append(rollups, g)
+	}
+
+	sort.Slice(rollups, func(i, j int) bool {
+		if rollups[i].TotalVolume != rollups[j].TotalVolume {
+			return rollups[i].TotalVolume > rollups[j].TotalVolume
+		}
+
+		return rollups[i].Group < rollups[j].Group
+	})
+
+	return rollups, nil
+}
+
+func (r *Rollup) add(p *Position) {
+	r.Count++
+	r.positionsSum += p.Position
+	r.TotalVolume += int64(p.Volume)
+
+	// Better position wins among keywords with equal volume
+	if r.TopKeyword == "" || p.Volume > r.topVolume || p.Volume == r.topVolume && p.Position < r.topPosition {
+		r.TopKeyword = p.Keyword
+		r.topVolume = p.Volume
+		r.topPosition = p.Position
+	}
+}
+
+// rollupKey returns a group of the url, the url is grouped as is if it can't be parsed.
+func rollupKey(rawURL, by string, depth int) string {
+	u, err := url.Parse(rawURL)
+	if err == nil && u.Host == "" && u.Scheme == "" {
+		// Urls without scheme, e.g. "fidel.net/blog"
+		u, err = url.Parse("http://" + rawURL)
+	}
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if by != RollupByPath {
+		return host
+	}
+
+	segments := make([]string, 0, depth)
+	for _, s := range strings.Split(u.Path, "/") {
+		if len(segments) == depth {
+			break
+		}
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+
+	return host + "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollupKey(t *testing.T) {
+	tests := []struct {
+		url      string
+		by       string
+		depth    int
+		expected string
+	}{
+		{"https://Allodial.fidel.net/unhat/serious.html", RollupByHost, 1, "allodial.fidel.net"},
+		{"https://fidel.net:8080/unhat", RollupByHost, 1, "fidel.net"},
+		{"https://fidel.net/unhat/serious.html", RollupByPath, 1, "fidel.net/unhat"},
+		{"https://fidel.net/unhat//serious.html?q=1", RollupByPath, 2, "fidel.net/unhat/serious.html"},
+		{"https://fidel.net/unhat", RollupByPath, 3, "fidel.net/unhat"},
+		{"https://fidel.net/", RollupByPath, 1, "fidel.net/"},
+		{"fidel.net/unhat", RollupByPath, 1, "fidel.net/unhat"},
+		{"://fidel.net", RollupByHost, 1, "://fidel.net"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, rollupKey(tt.url, tt.by, tt.depth), tt.url)
+	}
+}
+
+func TestRollupAdd(t *testing.T) {
+	r := &Rollup{}
+	r.add(&Position{Keyword: "serious", Position: 10, Volume: 100})
+	r.add(&Position{Keyword: "unhat", Position: 4, Volume: 100})
+	r.add(&Position{Keyword: "air", Position: 1, Volume: 50})
+
+	assert.Equal(t, 3, r.Count)
+	assert.Equal(t, int64(250), r.TotalVolume)
+	assert.Equal(t, "unhat", r.TopKeyword)
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	fts5CountSearchResultsQuery = `SELECT COUNT(1) FROM positions_fts WHERE positions_fts MATCH $1`
+
+	// Matches in keywords weigh more than the ones in urls.
+	fts5SearchPositionsQuery = `SELECT
+				p.domain,
+				p.keyword,
+				p.position,
+				p.url,
+				p.volume,
+				p.results,
+				p.cpc,
+				%[1]s,
+				%[2]s,
+				%[3]s
+		FROM positions_fts AS f
+		JOIN positions AS p ON p.domain = f.domain AND p.url = f.url AND p.keyword = f.keyword
+		WHERE positions_fts MATCH $1
+		ORDER BY bm25(positions_fts, 10.0, 1.0), p.volume DESC, p.domain, p.url, p.keyword
+		LIMIT $2 OFFSET $3
+`
+
+	// PostgreSQL builds documents on the fly, urls are split into words the same way FTS5 does.
+	tsqueryDocument = `setweight(to_tsvector('simple', keyword), 'A') ||
+			setweight(to_tsvector('simple', regexp_replace(url, '[^[:alnum:]]+', ' ', 'g')), 'D')`
+
+	tsqueryCountSearchResultsQuery = `SELECT COUNT(1)
+		FROM positions, to_tsquery('simple', $1) AS query
+		WHERE ` + tsqueryDocument + ` @@ query
+`
+
+	tsquerySearchPositionsQuery = `SELECT
+				domain,
+				keyword,
+				position,
+				url,
+				volume,
+				results,
+				cpc,
+				%[1]s,
+				%[2]s,
+				%[3]s
+		FROM positions, to_tsquery('simple', $1) AS query
+		WHERE ` + tsqueryDocument + ` @@ query
+		ORDER BY ts_rank(` + tsqueryDocument + `, query) DESC, volume DESC, domain, url, keyword
+		LIMIT $2 OFFSET $3
+`
+)
+
+var (
+	// ErrSearchUnavailable is returned if the search table doesn't exist, it isn't created by
+	// migrations if SQLite is built without FTS5.
+	ErrSearchUnavailable = errors.New("search is not available")
+	// ErrEmptySearchQuery is returned if a search query has no terms.
+	ErrEmptySearchQuery = errors.New("search query must contain at least one term")
+)
+
+// SearchResult represents a position found by a search query.
+type SearchResult struct {
+	Domain string `json:"domain"`
+	Position
+}
+
+// SearchPositionsOpts contains parameters of positions search.
+type SearchPositionsOpts struct {
+	// Query consists of whitespace-separated terms all of which must match, a term ending
+	// with '*' matches as a prefix.
+	Query  string
+	Limit  int
+	Offset int
+}
+
+// searchTerm is a word all search results must contain.
+type searchTerm struct {
+	text string
+	// prefix terms match words starting with the text.
+	prefix bool
+}
+
+// SearchPositions returns positions of all domains with keywords or urls matching the query,
+// the most relevant ones go first.
+func (pr *PositionRepo) SearchPositions(ctx context.Context, opts *SearchPositionsOpts) ([]*SearchResult, error) {
+	match, err := pr.searchMatch(ctx, opts.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(pr.dialect.searchPositionsQuery, pr.dialect.date("updated"), trafficSQL(), trafficValueSQL())
+	rows, err := pr.conn.QueryContext(ctx, query, match, opts.Limit, opts.Offset)
+	if err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]*SearchResult, 0)
+	for rows.Next() {
+		r := &SearchResult{}
+		if err := rows.Scan(&r.Domain,
+			&r.Keyword,
+			&r.Position.Position,
+			&r.URL,
+			&r.Volume,
+			&r.Results,
+			&r.CPC,
+			&r.Updated,
+			&r.Traffic,
+			&r.TrafficValue); err != nil {
+			pr.log.Error("failed to scan search result", zap.Error(err))
+
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		pr.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return results, nil
+}
+
+// CountSearchResults returns a number of positions matching the query.
+func (pr *PositionRepo) CountSearchResults(ctx context.Context, query string) (int, error) {
+	match, err := pr.searchMatch(ctx, query)
+	if err != nil {
+		return -1, err
+	}
+
+	var count int
+	if err := pr.conn.QueryRowxContext(ctx, pr.dialect.countSearchResultsQuery, match).Scan(&count); err != nil {
+		pr.log.Error("failed to count search results", zap.Error(err))
+
+		return -1, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	return count, nil
+}
+
+// searchMatch checks that the search is available and returns a full-text expression of the query.
+func (pr *PositionRepo) searchMatch(ctx context.Context, query string) (string, error) {
+	terms := parseSearchTerms(query)
+	if len(terms) == 0 {
+		return "", ErrEmptySearchQuery
+	}
+
+	if pr.dialect.searchTable != "" {
+		var exists int
+		if err := pr.conn.QueryRowxContext(ctx, pr.dialect.tableExistsQuery, pr.dialect.searchTable).
+			Scan(&exists); err != nil {
+			pr.log.Error("failed to check search table existence", zap.Error(err))
+
+			return "", fmt.Errorf("failed to check search table existence: %w", err)
+		}
+		if exists == 0 {
+			return "", ErrSearchUnavailable
+		}
+	}
+
+	return pr.dialect.matchExpression(terms), nil
+}
+
+// parseSearchTerms splits the query into terms, a trailing '*' of a term makes it a prefix one.
+// Quotes are dropped, so the query can't contain operators of full-text expressions.
+func parseSearchTerms(query string) []searchTerm {
+	terms := make([]searchTerm, 0)
+	for _, word := range strings.Fields(query) {
+		term := searchTerm{text: strings.Trim(word, `"*`), prefix: strings.HasSuffix(word, "*")}
+		if term.text != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms
+}
+
+// fts5MatchExpression quotes every term, so FTS5 treats them as strings rather than column filters.
+func fts5MatchExpression(terms []searchTerm) string {
+	quoted := make([]string, 0, len(terms))
+	for _, t := range terms {
+		q := `"` + strings.ReplaceAll(t.text, `"`, `""`) + `"`
+		if t.prefix {
+			q += "*"
+		}
+		quoted = append(quoted, q)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// tsqueryMatchExpression returns a tsquery matching all the terms.
+func tsqueryMatchExpression(terms []searchTerm) string {
+	quoted := make([]string, 0, len(terms))
+	for _, t := range terms {
+		q := "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(t.text) + "'"
+		if t.prefix {
+			q += ":*"
+		}
+		quoted = append(quoted, q)
+	}
+
+	return strings.Join(quoted, " & ")
+}
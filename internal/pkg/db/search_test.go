@@ -0,0 +1,29 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		query   string
+		fts5    string
+		tsquery string
+	}{
+		{"running shoe*", `"running" "shoe"*`, `'running' & 'shoe':*`},
+		{"  leather  ", `"leather"`, `'leather'`},
+		{`url:fidel OR "a`, `"url:fidel" "OR" "a"`, `'url:fidel' & 'OR' & 'a'`},
+		{`say"hi`, `"say""hi"`, `'say"hi'`},
+		{`it's a\b`, `"it's" "a\b"`, `'it''s' & 'a\\b'`},
+		{"* \"\"", "", ""},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		terms := parseSearchTerms(tt.query)
+		assert.Equal(t, tt.fts5, fts5MatchExpression(terms), tt.query)
+		assert.Equal(t, tt.tsquery, tsqueryMatchExpression(terms), tt.query)
+	}
+}
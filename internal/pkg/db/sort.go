@@ -0,0 +1,132 @@
+package db
+
+import "strings"
+
+// DefaultOrderBy is a field positions are ordered by if none is given.
+const DefaultOrderBy = "volume"
+
+// descPrefix marks fields of the order that are sorted in descending order, e.g. "-volume,position".
+const descPrefix = "-"
+
+// tieBreakers make the order of a domain's positions unique, since (domain, url, keyword)
+// is a primary key of 'positions' table.
+var tieBreakers = []string{"url", "keyword"}
+
+type columnKind int
+
+const (
+	kindInt columnKind = iota
+	kindFloat
+	kindString
+)
+
+// sortColumns contains kinds of columns positions can be ordered by.
+var sortColumns = map[string]columnKind{
+	"volume":   kindInt,
+	"results":  kindInt,
+	"updated":  kindInt,
+	"position": kindInt,
+	"cpc":      kindFloat,
+	"traffic":  kindFloat,
+	"url":      kindString,
+	"keyword":  kindString,
+}
+
+// changesSortColumns contains kinds of columns position changes are ordered by besides tie-breakers.
+var changesSortColumns = map[string]columnKind{
+	"abs_impact": kindInt,
+	"abs_delta":  kindInt,
+}
+
+// sortColumnKind returns a kind of a column positions or their changes are ordered by.
+func sortColumnKind(column string) columnKind {
+	if kind, ok := changesSortColumns[column]; ok {
+		return kind
+	}
+
+	return sortColumns[column]
+}
+
+// sortKey represents a single column of the order.
+type sortKey struct {
+	column string
+	desc   bool
+}
+
+// sql returns the key as an ORDER BY term.
+func (k sortKey) sql() string {
+	if k.desc {
+		return columnSQL(k.column) + " DESC"
+	}
+
+	return columnSQL(k.column) + " ASC"
+}
+
+// normalizeOrderBy returns the order in its canonical form, fields must be validated before.
+func normalizeOrderBy(orderBy string) string {
+	if orderBy == "" {
+		return DefaultOrderBy
+	}
+
+	fields := strings.Split(orderBy, ",")
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// sortKeys returns columns positions are ordered by followed by tie-breakers which aren't
+// a part of the order yet.
+func sortKeys(orderBy string) []sortKey {
+	var (
+		keys []sortKey
+		seen = make(map[string]struct{})
+	)
+	for _, f := range strings.Split(normalizeOrderBy(orderBy), ",") {
+		key := sortKey{column: strings.TrimPrefix(f, descPrefix), desc: strings.HasPrefix(f, descPrefix)}
+		keys = append(keys, key)
+		seen[key.column] = struct{}{}
+	}
+
+	for _, column := range tieBreakers {
+		if _, ok := seen[column]; !ok {
+			keys = append(keys, sortKey{column: column})
+		}
+	}
+
+	return keys
+}
+
+// orderBySQL returns ORDER BY terms of the order including tie-breakers.
+func orderBySQL(orderBy string) string {
+	keys := sortKeys(orderBy)
+	terms := make([]string, 0, len(keys))
+	for _, k := range keys {
+		terms = append(terms, k.sql())
+	}
+
+	return strings.Join(terms, ", ")
+}
+
+// sortValue returns a value of the position's column it's ordered by.
+func (p *Position) sortValue(column string) interface{} {
+	switch column {
+	case "volume":
+		return int64(p.Volume)
+	case "results":
+		return int64(p.Results)
+	case "updated":
+		return p.updatedAt
+	case "position":
+		return int64(p.Position)
+	case "cpc":
+		return p.CPC
+	case trafficColumn:
+		return p.Traffic
+	case "url":
+		return p.URL
+	default:
+		return p.Keyword
+	}
+}
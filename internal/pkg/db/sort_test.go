@@ -0,0 +1,20 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderBySQL(t *testing.T) {
+	tests := map[string]string{
+		"":                 "volume ASC, url ASC, keyword ASC",
+		"-volume,position": "volume DESC, position ASC, url ASC, keyword ASC",
+		"-keyword":         "keyword DESC, url ASC",
+		"url, -cpc":        "url ASC, cpc DESC, keyword ASC",
+	}
+
+	for orderBy, expected := range tests {
+		assert.Equal(t, expected, orderBySQL(orderBy), orderBy)
+	}
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+)
+
+// trafficColumn is a virtual column positions can be ordered by, it's computed from
+// volume and position.
+const trafficColumn = "traffic"
+
+// ctrCurve returns click-through rates of positions starting from the first one.
+func ctrCurve() []float64 {
+	if config.Config != nil && len(config.Config.Traffic.CTR) > 0 {
+		return config.Config.Traffic.CTR
+	}
+
+	return config.DefaultCTRCurve
+}
+
+// trafficSQL returns an expression of estimated traffic of a position, i.e. its volume
+// multiplied by CTR of its position, rounded to cents. Rates come from the config only,
+// so they are put into the query as is.
+func trafficSQL() string {
+	return roundSQL(ctrSQL() + " * volume")
+}
+
+// trafficValueSQL returns an expression of estimated traffic of a position multiplied by its CPC.
+func trafficValueSQL() string {
+	return roundSQL(ctrSQL() + " * volume * cpc")
+}
+
+// roundSQL rounds the expression to cents, PostgreSQL can round only numeric values.
+func roundSQL(expr string) string {
+	return "ROUND(CAST(" + expr + " AS NUMERIC), 2)"
+}
+
+// ctrSQL returns an expression of CTR of a position.
+func ctrSQL() string {
+	var sb strings.Builder
+	sb.WriteString("(CASE position")
+	for i, ctr := range ctrCurve() {
+		sb.WriteString(" WHEN ")
+		sb.WriteString(strconv.Itoa(i + 1))
+		sb.WriteString(" THEN ")
+		sb.WriteString(strconv.FormatFloat(ctr, 'f', -1, 64))
+	}
+	sb.WriteString(" ELSE 0 END)")
+
+	return sb.String()
+}
+
+// columnSQL returns an expression of a column positions can be ordered by.
+func columnSQL(column string) string {
+	if column == trafficColumn {
+		return trafficSQL()
+	}
+
+	return column
+}
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrafficSQL(t *testing.T) {
+	defer func(cfg *config.AppConfig) { config.Config = cfg }(config.Config)
+
+	config.Config = &config.AppConfig{Traffic: config.TrafficConfig{CTR: []float64{0.3, 0.15}}}
+	assert.Equal(t, "ROUND(CAST((CASE position WHEN 1 THEN 0.3 WHEN 2 THEN 0.15 ELSE 0 END) * volume AS NUMERIC), 2)", trafficSQL())
+	assert.Equal(t, trafficSQL(), columnSQL(trafficColumn))
+	assert.Equal(t, "volume", columnSQL("volume"))
+
+	// Default curve is used if the config is missing
+	config.Config = nil
+	assert.Equal(t, "(CASE position WHEN 1 THEN 0.316 WHEN 2 THEN 0.158 WHEN 3 THEN 0.1 WHEN 4 THEN 0.071 "+
+		"WHEN 5 THEN 0.051 WHEN 6 THEN 0.044 WHEN 7 THEN 0.03 WHEN 8 THEN 0.021 WHEN 9 THEN 0.019 "+
+		"WHEN 10 THEN 0.016 ELSE 0 END)", ctrSQL())
+}
@@ -0,0 +1,356 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	addUsageQuery = `INSERT INTO api_key_usage (api_key_id, day, requests, rows_returned, export_bytes)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (api_key_id, day) DO UPDATE SET
+				requests = api_key_usage.requests + excluded.requests,
+				rows_returned = api_key_usage.rows_returned + excluded.rows_returned,
+				export_bytes = api_key_usage.export_bytes + excluded.export_bytes
+`
+
+	selectUsageQuery = `SELECT day, requests, rows_returned, export_bytes
+		FROM api_key_usage
+		WHERE api_key_id = $1 AND day >= $2 AND day < $3
+		ORDER BY day
+`
+)
+
+// UsageDayLayout is a layout of days usage is aggregated by.
+const UsageDayLayout = "2006-01-02"
+
+// Usage represents consumption of the public API by a key.
+type Usage struct {
+	Requests    int64 `json:"requests"`
+	Rows        int64 `json:"rows"`
+	ExportBytes int64 `json:"export_bytes"`
+}
+
+// Add adds the other usage to the usage.
+func (u *Usage) Add(other Usage) {
+	u.Requests += other.Requests
+	u.Rows += other.Rows
+	u.ExportBytes += other.ExportBytes
+}
+
+// DailyUsage represents usage of a key during a day.
+type DailyUsage struct {
+	Day string `json:"day"`
+	Usage
+}
+
+// UsageRepo represents a data access layer to 'api_key_usage' table.
+type UsageRepo struct {
+	conn *sqlx.DB
+	log  *zap.Logger
+}
+
+// NewUsageRepo returns new instance of UsageRepo.
+func NewUsageRepo(log *zap.Logger, conn *sqlx.DB) *UsageRepo {
+	return &UsageRepo{
+		conn: conn,
+		log:  log,
+	}
+}
+
+// AddUsage adds the usage to the key's usage of the day of the given time in UTC.
+func (ur *UsageRepo) AddUsage(ctx context.Context, keyID string, at time.Time, u Usage) error {
+	if _, err := ur.conn.ExecContext(ctx, addUsageQuery, keyID, at.UTC().Format(UsageDayLayout),
+		u.Requests, u.Rows, u.ExportBytes); err != nil {
+		ur.log.Error("failed to add usage", zap.Error(err))
+
+		return fmt.Errorf("failed to add usage: %w", err)
+	}
+
+	return nil
+}
+
+// GetUsage returns the key's usage of days from the 'from' day to the 'to' day exclusive,
+// days without usage are omitted.
+func (ur *UsageRepo) GetUsage(ctx context.Context, keyID string, from, to time.Time) ([]*DailyUsage, error) {
+	rows, err := ur.conn.QueryContext(ctx, selectUsageQuery, keyID,
+		from.UTC().Format(UsageDayLayout), to.UTC().Format(UsageDayLayout))
+	if err != nil {
+		ur.log.Error("failed to execute query", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	days := make([]*DailyUsage, 0)
+	for rows.Next() {
+		d := &DailyUsage{}
+		if err := rows.Scan(&d.Day, &d.Requests, &d.Rows, &d.ExportBytes); err != nil {
+			ur.log.Error("failed to scan usage", zap.Error(err))
+
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		days = append(days, d)
+	}
+
+	return days, rows.Err()
+}
+
+// MonthBounds returns the first day of the month of the given time in UTC and the first day
+// of the next month.
+func MonthBounds(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	return from, from.AddDate(0, 1, 0)
+}
+
+// usageKey identifies usage of a key during a day.
+type usageKey struct {
+	keyID string
+	day   string
+}
+
+// rangeKey identifies usage of a key during days from the 'from' day to the 'to' day exclusive.
+type rangeKey struct {
+	keyID    string
+	from, to string
+}
+
+type storedUsage struct {
+	usage  Usage
+	loaded time.Time
+}
+
+// UsageMeter aggregates usage of keys by day in memory and adds it to the repository every
+// interval and on close, so that requests don't write to the DB. Stored totals are cached for
+// the interval, so usage metered by other processes is seen once they flush it.
+type UsageMeter struct {
+	repo     *UsageRepo
+	log      *zap.Logger
+	interval time.Duration
+
+	// flushMu is held by flushes, so that stored usage isn't read while it's being added.
+	flushMu sync.RWMutex
+
+	mu sync.Mutex
+	// pending is usage recorded since the last flush, flushing is usage being added to the repository.
+	pending  map[usageKey]Usage
+	flushing map[usageKey]Usage
+	stored   map[rangeKey]storedUsage
+
+	stop chan struct{}
+	done chan struct{}
+	now  func() time.Time
+}
+
+// NewUsageMeter returns new instance of UsageMeter flushing usage every interval until it's closed.
+func NewUsageMeter(log *zap.Logger, conn *sqlx.DB, interval time.Duration) *UsageMeter {
+	m := &UsageMeter{
+		repo:     NewUsageRepo(log, conn),
+		log:      log,
+		interval: interval,
+		pending:  make(map[usageKey]Usage),
+		stored:   make(map[rangeKey]storedUsage),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		now:      time.Now,
+	}
+	go m.run()
+
+	return m
+}
+
+func (m *UsageMeter) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			// Usage failed to be added is kept for the next flush
+			_ = m.Flush(context.Background())
+		}
+	}
+}
+
+// Close stops periodic flushes and flushes the rest of usage.
+func (m *UsageMeter) Close() {
+	close(m.stop)
+	<-m.done
+
+	if err := m.Flush(context.Background()); err != nil {
+		m.log.Error("usage is lost on shutdown", zap.Error(err))
+	}
+}
+
+// Record adds the usage to the key's usage of the day of the given time in UTC.
+func (m *UsageMeter) Record(keyID string, at time.Time, u Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := usageKey{keyID: keyID, day: at.UTC().Format(UsageDayLayout)}
+	total := m.pending[k]
+	total.Add(u)
+	m.pending[k] = total
+}
+
+// Flush adds usage recorded since the previous flush to the repository, usage failed to be
+// added is kept for the next flush.
+func (m *UsageMeter) Flush(ctx context.Context) error {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	m.mu.Lock()
+	if len(m.pending) == 0 {
+		m.mu.Unlock()
+
+		return nil
+	}
+	flushing := m.pending
+	m.flushing, m.pending = flushing, make(map[usageKey]Usage)
+	m.mu.Unlock()
+
+	var lastErr error
+	failed := make(map[usageKey]Usage)
+	for k, u := range flushing {
+		day, _ := time.Parse(UsageDayLayout, k.day)
+		if err := m.repo.AddUsage(ctx, k.keyID, day, u); err != nil {
+			failed[k] = u
+			lastErr = err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for k, u := range failed {
+		total := m.pending[k]
+		total.Add(u)
+		m.pending[k] = total
+	}
+	m.flushing = nil
+
+	// Cached totals of the flushed keys miss the added usage
+	flushed := make(map[string]struct{})
+	for k := range flushing {
+		flushed[k.keyID] = struct{}{}
+	}
+	for k := range m.stored {
+		if _, ok := flushed[k.keyID]; ok {
+			delete(m.stored, k)
+		}
+	}
+
+	if lastErr != nil {
+		m.log.Error("failed to flush usage", zap.Int("failed", len(failed)), zap.Error(lastErr))
+	}
+
+	return lastErr
+}
+
+// Total returns the key's usage of days from the 'from' day to the 'to' day exclusive,
+// including usage which isn't flushed yet.
+func (m *UsageMeter) Total(ctx context.Context, keyID string, from, to time.Time) (Usage, error) {
+	rk := rangeKey{keyID: keyID, from: from.UTC().Format(UsageDayLayout), to: to.UTC().Format(UsageDayLayout)}
+
+	m.mu.Lock()
+	if stored, ok := m.stored[rk]; ok && m.now().Sub(stored.loaded) < m.interval {
+		defer m.mu.Unlock()
+
+		return m.withUnflushed(stored.usage, rk), nil
+	}
+	m.mu.Unlock()
+
+	m.flushMu.RLock()
+	defer m.flushMu.RUnlock()
+
+	days, err := m.repo.GetUsage(ctx, keyID, from, to)
+	if err != nil {
+		return Usage{}, err
+	}
+	stored := storedUsage{loaded: m.now()}
+	for _, d := range days {
+		stored.usage.Add(d.Usage)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stored[rk] = stored
+
+	return m.withUnflushed(stored.usage, rk), nil
+}
+
+// GetUsage returns the key's usage of days from the 'from' day to the 'to' day exclusive,
+// including usage which isn't flushed yet, days without usage are omitted.
+func (m *UsageMeter) GetUsage(ctx context.Context, keyID string, from, to time.Time) ([]*DailyUsage, error) {
+	m.flushMu.RLock()
+	defer m.flushMu.RUnlock()
+
+	days, err := m.repo.GetUsage(ctx, keyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byDay := make(map[string]*DailyUsage, len(days))
+	for _, d := range days {
+		byDay[d.Day] = d
+	}
+	rk := rangeKey{keyID: keyID, from: from.UTC().Format(UsageDayLayout), to: to.UTC().Format(UsageDayLayout)}
+	for day, u := range m.unflushed(rk) {
+		d, ok := byDay[day]
+		if !ok {
+			d = &DailyUsage{Day: day}
+			byDay[day] = d
+			days = append(days, d)
+		}
+		d.Add(u)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Day < days[j].Day
+	})
+
+	return days, nil
+}
+
+// withUnflushed adds usage of the range which isn't in the repository yet to the stored usage,
+// it must be called with the lock held.
+func (m *UsageMeter) withUnflushed(stored Usage, rk rangeKey) Usage {
+	for _, u := range m.unflushed(rk) {
+		stored.Add(u)
+	}
+
+	return stored
+}
+
+// unflushed returns usage of the range which isn't in the repository yet by day, it must be
+// called with the lock held.
+func (m *UsageMeter) unflushed(rk rangeKey) map[string]Usage {
+	days := make(map[string]Usage)
+	for _, usage := range []map[usageKey]Usage{m.pending, m.flushing} {
+		for k, u := range usage {
+			if k.keyID != rk.keyID || k.day < rk.from || k.day >= rk.to {
+				continue
+			}
+			total := days[k.day]
+			total.Add(u)
+			days[k.day] = total
+		}
+	}
+
+	return days
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/log"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsage(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.CreateSchema(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	ctx := context.Background()
+	repo := NewUsageRepo(logger, b.DB)
+
+	day := time.Date(2020, 12, 31, 23, 0, 0, 0, time.UTC)
+	assert.NoError(t, repo.AddUsage(ctx, "a", day, Usage{Requests: 1, Rows: 10}))
+	assert.NoError(t, repo.AddUsage(ctx, "a", day.Add(30*time.Minute), Usage{Requests: 1, ExportBytes: 100}))
+	assert.NoError(t, repo.AddUsage(ctx, "a", day.AddDate(0, 0, -1), Usage{Requests: 1}))
+	assert.NoError(t, repo.AddUsage(ctx, "a", day.Add(time.Hour), Usage{Requests: 1}))
+	assert.NoError(t, repo.AddUsage(ctx, "b", day, Usage{Requests: 1}))
+
+	from, to := MonthBounds(day)
+	assert.Equal(t, time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), to)
+
+	got, err := repo.GetUsage(ctx, "a", from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, []*DailyUsage{
+		{Day: "2020-12-30", Usage: Usage{Requests: 1}},
+		{Day: "2020-12-31", Usage: Usage{Requests: 2, Rows: 10, ExportBytes: 100}},
+	}, got)
+}
+
+func TestUsageMeter(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.CreateSchema(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	ctx := context.Background()
+	repo := NewUsageRepo(logger, b.DB)
+	meter := NewUsageMeter(logger, b.DB, time.Hour)
+	now := time.Date(2020, 12, 31, 12, 0, 0, 0, time.UTC)
+	meter.now = func() time.Time { return now }
+	from, to := MonthBounds(now)
+
+	meter.Record("a", now, Usage{Requests: 1, Rows: 10})
+	meter.Record("a", now, Usage{Requests: 1, ExportBytes: 100})
+	meter.Record("a", now.AddDate(0, 0, -31), Usage{Requests: 1})
+	meter.Record("b", now, Usage{Requests: 1})
+
+	// Nothing is stored before the flush
+	days, err := repo.GetUsage(ctx, "a", from, to)
+	assert.NoError(t, err)
+	assert.Empty(t, days)
+
+	total, err := meter.Total(ctx, "a", from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, Usage{Requests: 2, Rows: 10, ExportBytes: 100}, total)
+
+	// Usage which failed to be stored is kept
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Error(t, meter.Flush(canceled))
+	total, err = meter.Total(ctx, "a", from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, Usage{Requests: 2, Rows: 10, ExportBytes: 100}, total)
+
+	assert.NoError(t, meter.Flush(ctx))
+	days, err = repo.GetUsage(ctx, "a", from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, []*DailyUsage{{Day: "2020-12-31", Usage: Usage{Requests: 2, Rows: 10, ExportBytes: 100}}}, days)
+
+	// Stored and unflushed usage is summed up
+	meter.Record("a", now, Usage{Requests: 1, Rows: 5})
+	meter.Record("a", now.AddDate(0, 0, -1), Usage{Requests: 1})
+	total, err = meter.Total(ctx, "a", from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, Usage{Requests: 4, Rows: 15, ExportBytes: 100}, total)
+
+	days, err = meter.GetUsage(ctx, "a", from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, []*DailyUsage{
+		{Day: "2020-12-30", Usage: Usage{Requests: 1}},
+		{Day: "2020-12-31", Usage: Usage{Requests: 3, Rows: 15, ExportBytes: 100}},
+	}, days)
+
+	// Usage stored by other processes is seen once the cached total expires
+	assert.NoError(t, repo.AddUsage(ctx, "a", now, Usage{Requests: 1}))
+	total, err = meter.Total(ctx, "a", from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), total.Requests)
+
+	now = now.Add(time.Hour)
+	total, err = meter.Total(ctx, "a", from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), total.Requests)
+
+	// The rest of usage is stored on close
+	meter.Close()
+	days, err = repo.GetUsage(ctx, "b", from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, []*DailyUsage{{Day: "2020-12-31", Usage: Usage{Requests: 1}}}, days)
+}
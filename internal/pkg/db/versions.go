@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	bumpVersionQuery = `INSERT INTO dataset_versions (domain, version, modified)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (domain) DO UPDATE SET
+				version = dataset_versions.version + 1,
+				modified = excluded.modified
+`
+
+	getVersionQuery = `SELECT version, modified FROM dataset_versions WHERE domain = $1`
+)
+
+// DatasetVersion tells when positions have been written last time, the version is
+// incremented by every write.
+type DatasetVersion struct {
+	Version  int64
+	Modified time.Time
+}
+
+// GetDatasetVersion returns a version of the domain's positions or of all positions if the domain
+// is empty, zero version is returned if nothing has been written yet.
+func (pr *PositionRepo) GetDatasetVersion(ctx context.Context, domain string) (*DatasetVersion, error) {
+	var version, modified int64
+	err := pr.conn.QueryRowxContext(ctx, getVersionQuery, domain).Scan(&version, &modified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &DatasetVersion{}, nil
+	}
+	if err != nil {
+		pr.log.Error("failed to get dataset version", zap.Error(err))
+
+		return nil, fmt.Errorf("failed to get dataset version: %w", err)
+	}
+
+	return &DatasetVersion{Version: version, Modified: time.Unix(modified, 0).UTC()}, nil
+}
+
+// bumpVersions increments versions of the written domains and of all positions within the transaction.
+func (pr *PositionRepo) bumpVersions(ctx context.Context, tx *sqlx.Tx, batch []*DomainPositions, now time.Time) error {
+	domains := make(map[string]struct{}, len(batch))
+	for _, dp := range batch {
+		if len(dp.Positions) > 0 {
+			domains[dp.Domain] = struct{}{}
+		}
+	}
+	if len(domains) == 0 {
+		return nil
+	}
+	domains[""] = struct{}{}
+
+	for domain := range domains {
+		if _, err := tx.ExecContext(ctx, bumpVersionQuery, domain, now.Unix()); err != nil {
+			pr.log.Error("failed to bump dataset version", zap.Error(err))
+
+			return fmt.Errorf("failed to bump dataset version: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/cache"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/log"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDatasetVersion(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	ctx := context.Background()
+	repo := NewPositionRepo(logger, b.DB)
+
+	got, err := repo.GetDatasetVersion(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, &DatasetVersion{}, got)
+
+	batch := []*DomainPositions{
+		{
+			Domain: testutils.TestDomain,
+			Positions: []*Position{
+				{URL: "http://ulmart.ru/test1", Keyword: "test1", Position: 1, Updated: "2017-05-20"},
+			},
+		},
+	}
+
+	// Dry run writes nothing
+	_, err = repo.ImportPositions(ctx, batch, true)
+	assert.NoError(t, err)
+	got, err = repo.GetDatasetVersion(ctx, testutils.TestDomain)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), got.Version)
+
+	start := time.Now().Truncate(time.Second)
+	for i := 0; i < 2; i++ {
+		_, err = repo.ImportPositions(ctx, batch, false)
+		assert.NoError(t, err)
+	}
+
+	for _, domain := range []string{"", testutils.TestDomain} {
+		got, err = repo.GetDatasetVersion(ctx, domain)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), got.Version, domain)
+		assert.False(t, got.Modified.Before(start), domain)
+	}
+
+	got, err = repo.GetDatasetVersion(ctx, "ozon.ru")
+	assert.NoError(t, err)
+	assert.Equal(t, &DatasetVersion{}, got)
+}
+
+func TestWatchVersion(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	b, err := backend.New(logger)
+	defer b.Shutdown()
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := cache.New(10, time.Minute)
+	repo := NewPositionRepo(logger, b.DB)
+	assert.NoError(t, WatchVersion(ctx, logger, repo, c, 10*time.Millisecond))
+
+	cached := NewCachedRepo(repo, c)
+	count, err := cached.GetSummary(ctx, testutils.TestDomain)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// Another process writes positions bypassing the cache
+	_, err = repo.ImportPositions(ctx, []*DomainPositions{
+		{
+			Domain: testutils.TestDomain,
+			Positions: []*Position{
+				{URL: "http://ulmart.ru/test5", Keyword: "test5", Position: 5, Updated: "2017-05-21"},
+			},
+		},
+	}, false)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		count, err := cached.GetSummary(ctx, testutils.TestDomain)
+
+		return err == nil && count == 4
+	}, time.Second, 10*time.Millisecond)
+}
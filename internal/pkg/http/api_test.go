@@ -1,12 +1,19 @@
 package http
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
 	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
 	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
@@ -14,16 +21,13 @@ import (
 	"github.com/dstdfx/solid-broccoli/internal/pkg/log"
 	"github.com/dstdfx/solid-broccoli/internal/pkg/testutils"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // Tests for GET /v1/summary/<domain-name>
 
 func TestGetSummaryOK(t *testing.T) {
-	// Check acceptance test flag
-	if !testutils.IsAccTestEnabled(t) {
-		return
-	}
-
 	// Init global app configuration
 	testutils.InitTestConfig()
 
@@ -61,14 +65,193 @@ func TestGetSummaryOK(t *testing.T) {
 		), w.Body.String())
 }
 
-// Tests for GET /v1/positions/<domain-name>
+func TestGetSummary_Cached(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+	config.Config.Cache = config.CacheConfig{Enabled: true, Size: 10, TTL: 60}
 
-func TestGetPositionsOK(t *testing.T) {
-	// Check acceptance test flag
-	if !testutils.IsAccTestEnabled(t) {
-		return
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	getSummary := func() string {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/summary/%s", testutils.TestDomain), nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		return w.Body.String()
+	}
+
+	// The second request is served from the cache, including its Last-Modified
+	assert.Equal(t, testutils.RespToJSON(t, v1.NewSummaryResponse(testutils.TestDomain, 3)), getSummary())
+	assert.Equal(t, testutils.RespToJSON(t, v1.NewSummaryResponse(testutils.TestDomain, 3)), getSummary())
+	assert.Equal(t, uint64(2), b.Cache.Stats().Hits)
+
+	// Writes purge the cache
+	w := httptest.NewRecorder()
+	body := `[{"url": "http://ulmart.ru/test5", "keyword": "test5", "position": 5, "volume": 10, "results": 100, "cpc": 0.5, "updated": "2017-05-21"}]`
+	r, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/v1/positions/%s", testutils.TestDomain), strings.NewReader(body))
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, testutils.RespToJSON(t, v1.NewSummaryResponse(testutils.TestDomain, 4)), getSummary())
+}
+
+func TestWarmUpCache(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+	config.Config.Cache = config.CacheConfig{Enabled: true, Size: 10, TTL: 60}
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	repo := db.NewCachedRepo(db.NewPositionRepo(logger, b.DB), b.Cache)
+	assert.NoError(t, repo.WarmUp(context.Background(), []string{testutils.TestDomain}, v1.DefaultLimit()))
+	assert.Equal(t, 5, b.Cache.Stats().Entries)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Requests without parameters are served from the cache
+	for _, url := range []string{
+		fmt.Sprintf("/v1/summary/%s", testutils.TestDomain),
+		fmt.Sprintf("/v1/summary/%s?include=top", testutils.TestDomain),
+		fmt.Sprintf("/v1/positions/%s", testutils.TestDomain),
+	} {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, url, nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
 	}
 
+	stats := b.Cache.Stats()
+	assert.Equal(t, uint64(7), stats.Hits)
+	assert.Equal(t, 5, stats.Entries)
+}
+
+func TestGetSummary_Conditional(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+	config.Config.PublicAPI.MaxAge = map[string]int{"summary": 300}
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	getSummary := func(headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/summary/%s", testutils.TestDomain), nil)
+		assert.NoError(t, err)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	// Fixture isn't written through the API, so the time of the last write is unknown
+	w := getSummary(nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(v1.LastModifiedHeader))
+
+	// Rewrite a position as is and pretend it's been done long ago
+	upsert := func() {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodPut, fmt.Sprintf("/v1/positions/%s", testutils.TestDomain), strings.NewReader(
+			`[{"url": "http://ulmart.ru/test1", "keyword": "test1", "position": 1, "volume": 43, "results": 40000, "cpc": 3.22, "updated": "2017-05-20"}]`))
+		assert.NoError(t, err)
+
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	upsert()
+	_, err = b.DB.Exec(`UPDATE dataset_versions SET modified = 1495324800`)
+	assert.NoError(t, err)
+
+	w = getSummary(nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "max-age=300", w.Header().Get(v1.CacheControlHeader))
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	etag := w.Header().Get(v1.ETagHeader)
+	assert.NotEmpty(t, etag)
+	lastModified := w.Header().Get(v1.LastModifiedHeader)
+	assert.Equal(t, "Sun, 21 May 2017 00:00:00 GMT", lastModified)
+
+	w = getSummary(map[string]string{v1.IfNoneMatchHeader: etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get(v1.ETagHeader))
+
+	w = getSummary(map[string]string{v1.IfModifiedSinceHeader: lastModified})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = getSummary(map[string]string{v1.IfNoneMatchHeader: `"outdated"`, v1.IfModifiedSinceHeader: lastModified})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, testutils.RespToJSON(t, v1.NewSummaryResponse(testutils.TestDomain, 3)), w.Body.String())
+
+	// A write modifies the domain even if 'updated' dates stay the same
+	upsert()
+	w = getSummary(map[string]string{v1.IfModifiedSinceHeader: lastModified})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, lastModified, w.Header().Get(v1.LastModifiedHeader))
+}
+
+// Tests for GET /v1/positions/<domain-name>
+
+func TestGetPositionsOK(t *testing.T) {
 	// Init global app configuration
 	testutils.InitTestConfig()
 
@@ -100,22 +283,17 @@ func TestGetPositionsOK(t *testing.T) {
 	router.ServeHTTP(w, r)
 
 	repo := db.NewPositionRepo(logger, b.DB)
-	positions, err := repo.GetPositions(context.Background(), testutils.TestDomain, "", 10, 0)
+	positions, err := repo.GetPositions(context.Background(), &db.GetPositionsOpts{Domain: testutils.TestDomain, Limit: 10})
 	assert.NoError(t, err)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t,
 		testutils.RespToJSON(t,
-			v1.NewPositionsResponse(testutils.TestDomain, positions),
+			v1.NewPositionsResponse(testutils.TestDomain, positions, "", v1.NewPaginationMeta(3, 1, 10, false)),
 		), w.Body.String())
 }
 
 func TestGetPositions_BadOrderByField(t *testing.T) {
-	// Check acceptance test flag
-	if !testutils.IsAccTestEnabled(t) {
-		return
-	}
-
 	// Init global app configuration
 	testutils.InitTestConfig()
 
@@ -152,3 +330,1357 @@ func TestGetPositions_BadOrderByField(t *testing.T) {
 			map[string]string{"error": "positions can't be ordered by 'wwwwat' field"},
 		), w.Body.String())
 }
+
+func TestGetPositions_RateLimited(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+	config.Config.PublicAPI.RateLimit = config.RateLimitConfig{
+		Enabled: true,
+		Default: config.RateLimit{Rate: 0.01, Burst: 5},
+		Routes:  map[string]config.RateLimit{"positions": {Rate: 0.01, Burst: 2}},
+	}
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	get := func(url, forwardedFor string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		if forwardedFor != "" {
+			r.Header.Set(v1.XForwardedForHeader, forwardedFor)
+		}
+
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+	positionsURL := fmt.Sprintf("/v1/positions/%s", testutils.TestDomain)
+
+	w := get(positionsURL, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get(v1.RateLimitLimitHeader))
+	assert.Equal(t, "1", w.Header().Get(v1.RateLimitRemainingHeader))
+	assert.Equal(t, "100", w.Header().Get(v1.RateLimitResetHeader))
+
+	assert.Equal(t, http.StatusOK, get(positionsURL, "").Code)
+
+	w = get(positionsURL, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get(v1.RateLimitRemainingHeader))
+	assert.Equal(t, "100", w.Header().Get(v1.RetryAfterHeader))
+	assert.Equal(t, testutils.RespToJSON(t, map[string]string{"error": "rate limit exceeded"}), w.Body.String())
+
+	// Other routes have their own limits
+	w = get(fmt.Sprintf("/v1/summary/%s", testutils.TestDomain), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get(v1.RateLimitLimitHeader))
+
+	// The client isn't a trusted proxy, so forwarded addresses are ignored
+	assert.Equal(t, http.StatusTooManyRequests, get(positionsURL, "198.51.100.1").Code)
+
+	assert.Equal(t, map[string]uint64{"positions": 2}, b.RateLimiter.Throttled())
+}
+
+// Tests for POST /v1/positions/<domain-name>
+
+func TestUpsertPositionsOK(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/v1/positions/%s", testutils.TestDomain)
+	body := `[
+{"url": "http://ulmart.ru/test1", "keyword": "test1", "position": 2, "volume": 43, "results": 40000, "cpc": 3.22, "updated": "2017-05-21"},
+{"url": "http://ulmart.ru/test5", "keyword": "test5", "position": 5, "volume": 10, "results": 100, "cpc": 0.5, "updated": "2017-05-21"}
+]`
+	r, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t,
+		testutils.RespToJSON(t,
+			v1.NewUpsertPositionsResponse(testutils.TestDomain, &db.UpsertResult{Inserted: 1, Updated: 1}),
+		), w.Body.String())
+}
+
+func TestUpsertPositions_ValidationFailed(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/v1/positions/%s", testutils.TestDomain)
+	body := `[
+{"url": "http://ulmart.ru/test5", "keyword": "test5", "position": 5, "updated": "2017-05-21"},
+{"url": "http://ulmart.ru/test6", "keyword": "", "position": 5, "updated": "2017-05-21"},
+{"url": "http://ulmart.ru/test7", "keyword": "test7", "position": 5, "updated": "21.05.2017"}
+]`
+	r, err := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t,
+		testutils.RespToJSON(t,
+			v1.NewValidationErrorResponse([]*v1.RowError{
+				{Index: 1, Error: "keyword is required"},
+				{Index: 2, Error: "updated must be a date in 2006-01-02 format"},
+			}),
+		), w.Body.String())
+
+	// Check that nothing has been written
+	repo := db.NewPositionRepo(logger, b.DB)
+	count, err := repo.GetSummary(context.Background(), testutils.TestDomain)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// Too large body is rejected before it's read
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+	assert.NoError(t, err)
+	r.ContentLength = 20480001
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.JSONEq(t, `{"error": "request body must not be larger than 20480000 bytes"}`, w.Body.String())
+
+	// Body of unknown length is read up to the limit
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodPut, url, strings.NewReader("["+strings.Repeat(" ", 20480000)+"]"))
+	assert.NoError(t, err)
+	r.ContentLength = -1
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "failed to decode positions: http: request body too large"}`, w.Body.String())
+}
+
+// Tests for GET /v1/positions/<domain-name>/export
+
+func TestExportPositionsCSV(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/v1/positions/%s/export?orderBy=cpc", testutils.TestDomain)
+	r, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `keyword,position,url,volume,results,cpc,updated
+test2,2,http://ulmart.ru/test2,55,40000,1.22,2017-05-20
+test3,3,http://ulmart.ru/test3,76,40000,2.22,2017-05-20
+test1,1,http://ulmart.ru/test1,43,40000,3.22,2017-05-20
+`, w.Body.String())
+}
+
+func TestExportPositions_Compressed(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+	config.Config.PublicAPI.Compression = config.CompressionConfig{Enabled: true, MinSize: 1024}
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	// Export is flushed a few times while it's compressed
+	fixture := &testutils.Fixture{}
+	for i := 0; i < 2500; i++ {
+		fixture.Positions = append(fixture.Positions, testutils.FixturePosition{
+			Domain:   "fidel.net",
+			Keyword:  fmt.Sprintf("keyword %d", i),
+			URL:      fmt.Sprintf("https://fidel.net/%d", i),
+			Position: i%100 + 1,
+			Volume:   100,
+			Results:  1000,
+			CPC:      1.5,
+			Updated:  1495324800,
+		})
+	}
+	testutils.CreateSchema(t, b.DB)
+	testutils.LoadFixture(t, b.DB, fixture)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "/v1/positions/fidel.net/export", nil)
+	assert.NoError(t, err)
+	r.Header.Set(v1.AcceptEncodingHeader, "gzip")
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get(v1.ContentEncodingHeader))
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+
+	gr, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(gr)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	assert.Len(t, lines, 2501)
+	assert.Equal(t, "keyword,position,url,volume,results,cpc,updated", lines[0])
+}
+
+func TestGetPositions_CompressedConditional(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+	config.Config.PublicAPI.Compression = config.CompressionConfig{Enabled: true, MinSize: 100}
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	getPositions := func(headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/positions/%s", testutils.TestDomain), nil)
+		assert.NoError(t, err)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	identity := getPositions(nil)
+	assert.Equal(t, http.StatusOK, identity.Code)
+	assert.Empty(t, identity.Header().Get(v1.ContentEncodingHeader))
+
+	w := getPositions(map[string]string{v1.AcceptEncodingHeader: "br"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "br", w.Header().Get(v1.ContentEncodingHeader))
+	body, err := ioutil.ReadAll(brotli.NewReader(w.Body))
+	assert.NoError(t, err)
+	assert.Equal(t, identity.Body.String(), string(body))
+
+	// Compressed and uncompressed representations have different ETags
+	etag := w.Header().Get(v1.ETagHeader)
+	assert.Equal(t, strings.TrimSuffix(identity.Header().Get(v1.ETagHeader), `"`)+`-br"`, etag)
+
+	w = getPositions(map[string]string{v1.AcceptEncodingHeader: "br", v1.IfNoneMatchHeader: etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, etag, w.Header().Get(v1.ETagHeader))
+
+	w = getPositions(map[string]string{v1.AcceptEncodingHeader: "gzip", v1.IfNoneMatchHeader: etag})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get(v1.ContentEncodingHeader))
+}
+
+func TestExportPositionsNDJSON(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/v1/positions/%s/export?format=ndjson", testutils.TestDomain)
+	r, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	repo := db.NewPositionRepo(logger, b.DB)
+	positions, err := repo.GetPositions(context.Background(), &db.GetPositionsOpts{Domain: testutils.TestDomain, Limit: 10})
+	assert.NoError(t, err)
+
+	expected := ""
+	for _, p := range positions {
+		expected += testutils.RespToJSON(t, p)
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, expected, w.Body.String())
+}
+
+func TestExportPositions_BadFormat(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/v1/positions/%s/export?format=xlsx", testutils.TestDomain)
+	r, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t,
+		testutils.RespToJSON(t,
+			map[string]string{"error": "positions can't be exported in 'xlsx' format"},
+		), w.Body.String())
+}
+
+func TestGetPositions_BadCursor(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request with a cursor of another order.
+	w := httptest.NewRecorder()
+	cursor := db.NewCursor("cpc", &db.Position{}).Encode()
+	url := fmt.Sprintf("/v1/positions/%s?orderBy=url&cursor=%s", testutils.TestDomain, cursor)
+	r, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t,
+		testutils.RespToJSON(t,
+			map[string]string{"error": "cursor can't be used with positions ordered by 'url'"},
+		), w.Body.String())
+}
+
+func TestGetPositions_Pagination(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request of the second page.
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/v1/positions/%s?limit=1&page=2", testutils.TestDomain)
+	r, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	repo := db.NewPositionRepo(logger, b.DB)
+	positions, err := repo.GetPositions(context.Background(), &db.GetPositionsOpts{
+		Domain: testutils.TestDomain,
+		Limit:  1,
+		Offset: 1,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t,
+		testutils.RespToJSON(t,
+			v1.NewPositionsResponse(testutils.TestDomain, positions,
+				db.NewCursor("", positions[0]).Encode(), v1.NewPaginationMeta(3, 2, 1, true)),
+		), w.Body.String())
+	assert.Equal(t, `</v1/positions/ulmart.ru?limit=1&page=1>; rel="first", `+
+		`</v1/positions/ulmart.ru?limit=1&page=1>; rel="prev", `+
+		`</v1/positions/ulmart.ru?limit=1&page=3>; rel="next", `+
+		`</v1/positions/ulmart.ru?limit=1&page=3>; rel="last"`, w.Header().Get("Link"))
+
+	// Test a request with too large limit.
+	w = httptest.NewRecorder()
+	url = fmt.Sprintf("/v1/positions/%s?limit=1000", testutils.TestDomain)
+	r, err = http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t,
+		testutils.RespToJSON(t,
+			map[string]string{"error": "'limit' must be an integer between 1 and 100"},
+		), w.Body.String())
+
+	// Test a request of a page whose offset overflows.
+	w = httptest.NewRecorder()
+	url = fmt.Sprintf("/v1/positions/%s?page=9223372036854775807", testutils.TestDomain)
+	r, err = http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t,
+		testutils.RespToJSON(t,
+			map[string]string{"error": "'page' must not be greater than 214748365"},
+		), w.Body.String())
+}
+
+func TestGetSummary_Include(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/v1/summary/%s?include=top,position,updated,traffic", testutils.TestDomain)
+	r, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"domain": "ulmart.ru",
+		"positions_count": 3,
+		"top": {"top_3": 3, "top_10": 3, "top_100": 3},
+		"position": {"average": 2, "median": 2},
+		"updated": {"min": "2017-05-20", "max": "2017-05-20"},
+		"traffic": {"estimated": 29.88, "value": 71.22}
+	}`, w.Body.String())
+}
+
+func TestGetSummary_Fixture(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+	defer b.Shutdown()
+
+	testutils.CreateSchema(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+	testutils.LoadFixture(t, b.DB, &testutils.Fixture{
+		Positions: []testutils.FixturePosition{
+			{Domain: "fidel.net", Keyword: "leather bag", URL: "https://fidel.net/bags", Position: 2,
+				Volume: 1000, Results: 100, CPC: 1.5, Updated: 1495248847},
+		},
+	})
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "/v1/summary/fidel.net?include=top,traffic", nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"domain": "fidel.net",
+		"positions_count": 1,
+		"top": {"top_3": 1, "top_10": 1, "top_100": 1},
+		"traffic": {"estimated": 158, "value": 237}
+	}`, w.Body.String())
+}
+
+func TestGetHistoryOK(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/v1/history/%s?keyword=test1&from=2017-05-18", testutils.TestDomain)
+	r, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"domain": "ulmart.ru",
+		"history": [
+			{
+				"keyword": "test1",
+				"url": "http://ulmart.ru/test1",
+				"points": [
+					{"position": 3, "volume": 41, "results": 40000, "cpc": 3.15, "updated": "2017-05-18"},
+					{"position": 1, "volume": 43, "results": 40000, "cpc": 3.22, "updated": "2017-05-20"}
+				]
+			}
+		]
+	}`, w.Body.String())
+
+	// Neither keyword nor url is given
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/history/%s", testutils.TestDomain), nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetChangesOK(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/v1/changes/%s?from=2017-05-18&to=2017-05-20&limit=1&page=2", testutils.TestDomain)
+	r, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	cursor := db.NewChangesCursor(&db.PositionChange{Keyword: "test2", URL: "http://ulmart.ru/test2", Delta: 99, Impact: 5445}).Encode()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, fmt.Sprintf(`{
+		"domain": "ulmart.ru",
+		"from": "2017-05-18",
+		"to": "2017-05-20",
+		"counts": {"new": 2, "lost": 0, "improved": 1, "declined": 0, "unchanged": 0},
+		"changes": [
+			{"keyword": "test2", "url": "http://ulmart.ru/test2", "type": "new", "previous_position": null,
+			 "position": 2, "delta": 99, "volume": 55, "impact": 5445}
+		],
+		"next_cursor": "%s",
+		"meta": {"total": 3, "pages": 3, "page": 2, "per_page": 1, "has_more": true}
+	}`, cursor), w.Body.String())
+	assert.Contains(t, w.Header().Get("Link"), `page=3&to=2017-05-20>; rel="next"`)
+
+	// The next page is continued with a cursor
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/changes/%s?from=2017-05-18&to=2017-05-20&limit=1&cursor=%s",
+		testutils.TestDomain, cursor), nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"domain": "ulmart.ru",
+		"from": "2017-05-18",
+		"to": "2017-05-20",
+		"counts": {"new": 2, "lost": 0, "improved": 1, "declined": 0, "unchanged": 0},
+		"changes": [
+			{"keyword": "test1", "url": "http://ulmart.ru/test1", "type": "improved", "previous_position": 3,
+			 "position": 1, "delta": 2, "volume": 43, "impact": 86}
+		],
+		"meta": {"total": 3, "pages": 3, "per_page": 1, "has_more": false}
+	}`, w.Body.String())
+
+	// Cursors of positions can't be used
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/changes/%s?from=2017-05-18&to=2017-05-20&cursor=%s",
+		testutils.TestDomain, db.NewCursor("", &db.Position{}).Encode()), nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Dates are required
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/changes/%s?from=2017-05-18", testutils.TestDomain), nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "both 'from' and 'to' are required"}`, w.Body.String())
+}
+
+func TestGetCompetitorsOK(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	url := fmt.Sprintf("/v1/competitors/%s", "eldorado.ru")
+	r, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"domain": "eldorado.ru",
+		"competitors": [
+			{"domain": "ulmart.ru", "shared_keywords": 2, "overlap": 100, "avg_position": 1.5, "shared_volume": 97}
+		],
+		"meta": {"total": 1, "pages": 1, "page": 1, "per_page": 10, "has_more": false}
+	}`, w.Body.String())
+}
+
+func TestGetCompetitors_Conditional(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	upsert := func(domain, body string) {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodPut, fmt.Sprintf("/v1/positions/%s", domain), strings.NewReader(body))
+		assert.NoError(t, err)
+
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	getCompetitors := func(headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, "/v1/competitors/eldorado.ru", nil)
+		assert.NoError(t, err)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	// Write the domain itself long ago
+	upsert("eldorado.ru", `[{"url": "http://eldorado.ru/test1", "keyword": "test1", "position": 4, "volume": 47, "results": 40000, "cpc": 3.22, "updated": "2017-05-20"}]`)
+	_, err = b.DB.Exec(`UPDATE dataset_versions SET modified = 1495324800`)
+	assert.NoError(t, err)
+
+	w := getCompetitors(nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	lastModified := w.Header().Get(v1.LastModifiedHeader)
+	assert.Equal(t, "Sun, 21 May 2017 00:00:00 GMT", lastModified)
+
+	w = getCompetitors(map[string]string{v1.IfModifiedSinceHeader: lastModified})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// A write of a rival domain modifies competitors of the domain
+	upsert(testutils.TestDomain, `[{"url": "http://ulmart.ru/test1", "keyword": "test1", "position": 5, "volume": 43, "results": 40000, "cpc": 3.22, "updated": "2017-05-21"}]`)
+
+	w = getCompetitors(map[string]string{v1.IfModifiedSinceHeader: lastModified})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"avg_position":3.5`)
+}
+
+func TestCompareDomainsOK(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "/v1/compare?domains=eldorado.ru,ulmart.ru&mode=missing", nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"domains": ["eldorado.ru", "ulmart.ru"],
+		"mode": "missing",
+		"keywords": [
+			{
+				"keyword": "test3",
+				"volume": 76,
+				"positions": {
+					"eldorado.ru": null,
+					"ulmart.ru": {"position": 3, "url": "http://ulmart.ru/test3"}
+				}
+			}
+		],
+		"meta": {"total": 1, "pages": 1, "page": 1, "per_page": 10, "has_more": false}
+	}`, w.Body.String())
+
+	// A single domain can't be compared
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodGet, "/v1/compare?domains=ulmart.ru", nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetKeywordOK(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "/v1/keywords/test1", nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"keyword": "test1",
+		"volume": 47,
+		"cpc": 3.22,
+		"results": 40000,
+		"updated": "2017-05-20",
+		"rankings": [
+			{"domain": "ulmart.ru", "url": "http://ulmart.ru/test1", "position": 1, "updated": "2017-05-20"},
+			{"domain": "eldorado.ru", "url": "http://eldorado.ru/test1", "position": 4, "updated": "2017-05-20"}
+		]
+	}`, w.Body.String())
+
+	// Nobody ranks for the keyword
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodGet, "/v1/keywords/unknown", nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error": "keyword not found"}`, w.Body.String())
+
+	// Escaped percent signs are a part of the keyword
+	for _, path := range []string{"/v1/keywords/test%2531", "/v1/keywords/50%25%20off", "/v1/keywords/a%2Fb%2525"} {
+		w = httptest.NewRecorder()
+		r, err = http.NewRequest(http.MethodGet, path, nil)
+		assert.NoError(t, err)
+
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
+
+func TestGetRollupsOK(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "/v1/positions/non-ulmart.ru/rollup?by=path&depth=2", nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"domain": "non-ulmart.ru",
+		"by": "path",
+		"depth": 2,
+		"rollups": [
+			{"group": "nonulmart.ru/tests", "count": 1, "avg_position": 11, "total_volume": 65, "top_keyword": "test4"},
+			{"group": "nonulmart.ru/tests/2", "count": 1, "avg_position": 7, "total_volume": 32, "top_keyword": "test4"}
+		]
+	}`, w.Body.String())
+}
+
+func TestSearchPositions(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	_, searchErr := db.NewPositionRepo(logger, b.DB).CountSearchResults(context.Background(), "test3")
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	// Test a request.
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "/v1/search?q=test3", nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	if errors.Is(searchErr, db.ErrSearchUnavailable) {
+		t.Log("SQLite is built without FTS5, run tests with 'sqlite_fts5' tag")
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+
+		return
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"query": "test3",
+		"positions": [
+			{"domain": "ulmart.ru", "keyword": "test3", "position": 3, "url": "http://ulmart.ru/test3",
+			 "volume": 76, "results": 40000, "cpc": 2.22, "updated": "2017-05-20", "traffic": 7.6, "traffic_value": 16.87}
+		],
+		"meta": {"total": 1, "pages": 1, "page": 1, "per_page": 10, "has_more": false}
+	}`, w.Body.String())
+
+	// Query without terms
+	w = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodGet, "/v1/search?q=%22%22", nil)
+	assert.NoError(t, err)
+
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Tests for API keys
+
+func TestAuthentication(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+	config.Config.PublicAPI.Auth.Enabled = true
+
+	// Access log is written to the buffer
+	var logs bytes.Buffer
+	logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.AddSync(&logs), zapcore.DebugLevel))
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	ctx := context.Background()
+	keys := db.NewAPIKeyRepo(logger, b.DB)
+	reader, readerKey, err := keys.CreateAPIKey(ctx, &db.CreateAPIKeyOpts{
+		Name:    "reader",
+		Scopes:  []string{db.ScopeRead},
+		Domains: []string{testutils.TestDomain},
+	})
+	assert.NoError(t, err)
+	_, writerKey, err := keys.CreateAPIKey(ctx, &db.CreateAPIKeyOpts{
+		Name:   "writer",
+		Scopes: []string{db.ScopeRead, db.ScopeWrite},
+	})
+	assert.NoError(t, err)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	request := func(method, url string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(method, url, strings.NewReader("[]"))
+		assert.NoError(t, err)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+	apiKey := func(key string) http.Header {
+		return http.Header{"X-Api-Key": {key}}
+	}
+	summaryURL := fmt.Sprintf("/v1/summary/%s", testutils.TestDomain)
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		header       http.Header
+		expectedCode int
+		expectedBody string
+	}{
+		{"no key", http.MethodGet, summaryURL, nil, http.StatusUnauthorized, `{"error":"API key is required"}`},
+		{"invalid key", http.MethodGet, summaryURL, apiKey("sb_invalid"), http.StatusUnauthorized, `{"error":"API key is invalid"}`},
+		{"valid key", http.MethodGet, summaryURL, apiKey(readerKey), http.StatusOK, ""},
+		{"bearer token", http.MethodGet, summaryURL, http.Header{"Authorization": {"Bearer " + readerKey}}, http.StatusOK, ""},
+		{"other domain", http.MethodGet, "/v1/summary/fidel.net", apiKey(readerKey), http.StatusForbidden,
+			`{"error":"API key has no access to domain 'fidel.net'"}`},
+		{"compared domains", http.MethodGet, "/v1/compare?domains=ulmart.ru,fidel.net", apiKey(readerKey), http.StatusForbidden,
+			`{"error":"API key has no access to domain 'fidel.net'"}`},
+		{"search", http.MethodGet, "/v1/search?q=test", apiKey(readerKey), http.StatusForbidden,
+			`{"error":"API key is restricted to a list of domains"}`},
+		{"no write scope", http.MethodPost, fmt.Sprintf("/v1/positions/%s", testutils.TestDomain), apiKey(readerKey), http.StatusForbidden,
+			`{"error":"API key has no 'write' scope"}`},
+		{"no export scope", http.MethodGet, fmt.Sprintf("/v1/positions/%s/export", testutils.TestDomain), apiKey(writerKey), http.StatusForbidden,
+			`{"error":"API key has no 'export' scope"}`},
+		{"any domain", http.MethodGet, "/v1/summary/fidel.net", apiKey(writerKey), http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		w := request(tt.method, tt.url, tt.header)
+		assert.Equal(t, tt.expectedCode, w.Code, tt.name)
+		if tt.expectedBody != "" {
+			assert.Equal(t, tt.expectedBody, strings.TrimSpace(w.Body.String()), tt.name)
+		}
+	}
+
+	// Responses of authenticated requests aren't stored by shared caches
+	w := request(http.MethodGet, summaryURL, apiKey(readerKey))
+	assert.Equal(t, "private, no-cache", w.Header().Get(v1.CacheControlHeader))
+	assert.Equal(t, "Bearer", request(http.MethodGet, summaryURL, nil).Header().Get(v1.WWWAuthenticateHeader))
+
+	// Access log shows who made the call
+	assert.Contains(t, logs.String(), fmt.Sprintf(`"api_key_id":"%s","api_key_name":"reader"`, reader.ID))
+
+	// Revoked keys are rejected
+	assert.NoError(t, keys.RevokeAPIKey(ctx, reader.ID))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, summaryURL, apiKey(readerKey)).Code)
+}
+
+func TestAuthentication_RateLimited(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+	config.Config.PublicAPI.Auth.Enabled = true
+	config.Config.PublicAPI.RateLimit = config.RateLimitConfig{
+		Enabled: true,
+		Default: config.RateLimit{Rate: 0.01, Burst: 5},
+		Routes:  map[string]config.RateLimit{"auth": {Rate: 0.01, Burst: 2}},
+	}
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	guess := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v1/summary/%s", testutils.TestDomain), nil)
+		r.Header.Set(v1.APIKeyHeader, "sb_guessed_secret")
+
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	// Invalid keys are limited by address before they're looked up
+	assert.Equal(t, http.StatusUnauthorized, guess().Code)
+	assert.Equal(t, http.StatusUnauthorized, guess().Code)
+	assert.Equal(t, http.StatusTooManyRequests, guess().Code)
+
+	assert.Equal(t, map[string]uint64{"auth": 1}, b.RateLimiter.Throttled())
+}
+
+// Tests for GET /v1/usage
+
+func TestUsage(t *testing.T) {
+	// Init global app configuration
+	testutils.InitTestConfig()
+	config.Config.PublicAPI.Auth.Enabled = true
+	config.Config.PublicAPI.Usage = config.UsageConfig{
+		Enabled:       true,
+		DefaultQuota:  config.Quota{Rows: 6},
+		FlushInterval: 60,
+	}
+
+	// Initialize logger
+	logger, err := log.InitLogger(log.InitLoggerOpts{
+		Debug:     config.Config.Log.Debug,
+		UseStdout: config.Config.Log.UseStdout,
+		File:      config.Config.Log.File,
+	})
+	assert.NoError(t, err)
+
+	// Prepare backend.
+	b, err := backend.New(logger)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	testutils.PrepareDB(t, b.DB)
+	defer testutils.TeardownDB(t, b.DB)
+
+	k, key, err := db.NewAPIKeyRepo(logger, b.DB).CreateAPIKey(context.Background(), &db.CreateAPIKeyOpts{
+		Name:   "metered",
+		Scopes: []string{db.ScopeRead, db.ScopeExport},
+	})
+	assert.NoError(t, err)
+
+	// Setup handlers
+	router := InitAPIRouter(logger, b)
+
+	get := func(url string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, url, nil)
+		assert.NoError(t, err)
+		r.Header.Set(v1.APIKeyHeader, key)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+	positionsURL := fmt.Sprintf("/v1/positions/%s", testutils.TestDomain)
+
+	w := get(positionsURL, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Rows of not modified responses aren't counted
+	assert.Equal(t, http.StatusNotModified, get(positionsURL, http.Header{"If-None-Match": {w.Header().Get(v1.ETagHeader)}}).Code)
+
+	w = get(fmt.Sprintf("/v1/positions/%s/export", testutils.TestDomain), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	exportBytes := int64(w.Body.Len())
+
+	month := time.Now().UTC().Format("2006-01")
+	w = get("/v1/usage", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, testutils.RespToJSON(t, v1.NewUsageResponse(k.ID, month, config.Quota{Rows: 6}, []*db.DailyUsage{{
+		Day:   time.Now().UTC().Format(db.UsageDayLayout),
+		Usage: db.Usage{Requests: 3, Rows: 6, ExportBytes: exportBytes},
+	}})), w.Body.String())
+
+	// The quota of rows is exhausted, usage can still be seen
+	w = get(positionsURL, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	_, to := db.MonthBounds(time.Now())
+	assert.Equal(t, testutils.RespToJSON(t, map[string]string{
+		"error":   "quota_exceeded",
+		"message": "monthly quota of 6 rows is exceeded until " + to.Format(db.UsageDayLayout),
+	}), w.Body.String())
+	assert.Equal(t, http.StatusOK, get("/v1/usage", nil).Code)
+
+	// Other months have no usage
+	w = get("/v1/usage?month=2020-01", nil)
+	assert.Equal(t, testutils.RespToJSON(t, v1.NewUsageResponse(k.ID, "2020-01", config.Quota{Rows: 6}, []*db.DailyUsage{})), w.Body.String())
+
+	w = get("/v1/usage?month=january", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, testutils.RespToJSON(t, map[string]string{"error": "'month' must be in YYYY-MM format"}), w.Body.String())
+
+	// Usage is kept in memory until it's flushed
+	from, to := db.MonthBounds(time.Now())
+	days, err := db.NewUsageRepo(logger, b.DB).GetUsage(context.Background(), k.ID, from, to)
+	assert.NoError(t, err)
+	assert.Empty(t, days)
+}
@@ -0,0 +1,180 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"go.uber.org/zap"
+)
+
+const (
+	APIKeyHeader          = "x-api-key"
+	AuthorizationHeader   = "authorization"
+	WWWAuthenticateHeader = "www-authenticate"
+
+	bearerPrefix = "Bearer "
+)
+
+// principal is a client the request is made by. RequestLogger puts an empty one into
+// the context before the client is known, so that the access log shows who made the call.
+type principal struct {
+	apiKey *db.APIKey
+}
+
+// Authenticate middleware requires a valid API key in X-API-Key or Authorization: Bearer
+// header. The key is saved into the context and its ID and name are added to the context
+// logger. It's a no-op if authentication is disabled.
+func Authenticate(b *backend.Backend) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if config.Config == nil || !config.Config.PublicAPI.Auth.Enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log, err := GetContextLogger(r.Context())
+			if err != nil {
+				http.Error(w, "", http.StatusInternalServerError)
+
+				return
+			}
+
+			key := requestAPIKey(r)
+			if key == "" {
+				unauthorized(w, "API key is required")
+
+				return
+			}
+
+			apiKey, err := db.NewAPIKeyRepo(log, b.DB).Authenticate(r.Context(), key)
+			if err != nil {
+				if errors.Is(err, db.ErrAPIKeyNotFound) {
+					unauthorized(w, "API key is invalid")
+
+					return
+				}
+				log.Error("failed to authenticate request", zap.Error(err))
+				http.Error(w, "", http.StatusInternalServerError)
+
+				return
+			}
+
+			ctx := r.Context()
+			p, ok := ctx.Value(ctxPrincipal).(*principal)
+			if !ok {
+				p = &principal{}
+				ctx = context.WithValue(ctx, ctxPrincipal, p)
+			}
+			p.apiKey = apiKey
+
+			ctx = context.WithValue(ctx, ctxLogger, log.With(principalFields(apiKey)...))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetAPIKey retrieves the API key the request is authenticated with, it's nil if
+// authentication is disabled.
+func GetAPIKey(ctx context.Context) *db.APIKey {
+	if p, ok := ctx.Value(ctxPrincipal).(*principal); ok {
+		return p.apiKey
+	}
+
+	return nil
+}
+
+// RequireScope middleware checks that the request's API key is granted the scope.
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := GetAPIKey(r.Context()); key != nil && !key.HasScope(scope) {
+				forbidden(w, fmt.Sprintf("API key has no '%s' scope", scope))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireDomainAccess middleware checks that the request's API key has access to the domain
+// set by RequireDomainName.
+func RequireDomainAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkDomainsAccess(w, r, GetDomainName(r.Context())) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAllDomains middleware is used by routes returning positions of any domain, keys
+// restricted to a list of domains can't use them.
+func RequireAllDomains(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := GetAPIKey(r.Context()); key != nil && !key.AllDomains() {
+			forbidden(w, "API key is restricted to a list of domains")
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkDomainsAccess replies with 403 Forbidden if the request's API key has no access to
+// any of the domains, it reports whether the request can proceed.
+func checkDomainsAccess(w http.ResponseWriter, r *http.Request, domains ...string) bool {
+	key := GetAPIKey(r.Context())
+	if key == nil {
+		return true
+	}
+	for _, domain := range domains {
+		if !key.AllowsDomain(domain) {
+			forbidden(w, fmt.Sprintf("API key has no access to domain '%s'", domain))
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// requestAPIKey returns a key of X-API-Key header or a bearer token of Authorization header.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+
+	authorization := r.Header.Get(AuthorizationHeader)
+	if len(authorization) > len(bearerPrefix) && strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		return strings.TrimSpace(authorization[len(bearerPrefix):])
+	}
+
+	return ""
+}
+
+func principalFields(key *db.APIKey) []zap.Field {
+	return []zap.Field{
+		zap.String("api_key_id", key.ID),
+		zap.String("api_key_name", key.Name),
+	}
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set(WWWAuthenticateHeader, "Bearer")
+	w.WriteHeader(http.StatusUnauthorized)
+	JSON(w, map[string]string{"error": msg})
+}
+
+func forbidden(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusForbidden)
+	JSON(w, map[string]string{"error": msg})
+}
@@ -0,0 +1,76 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestAPIKey(t *testing.T) {
+	tests := []struct {
+		header   string
+		value    string
+		expected string
+	}{
+		{APIKeyHeader, "sb_1", "sb_1"},
+		{AuthorizationHeader, "Bearer sb_1", "sb_1"},
+		{AuthorizationHeader, "bearer  sb_1 ", "sb_1"},
+		{AuthorizationHeader, "Basic c2I6MQ==", ""},
+		{AuthorizationHeader, "Bearer ", ""},
+		{UserAgentHeader, "sb_1", ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(tt.header, tt.value)
+
+		assert.Equal(t, tt.expected, requestAPIKey(r), tt.value)
+	}
+}
+
+func TestRequireScopeAndDomains(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	restricted := &db.APIKey{ID: "1", Scopes: []string{db.ScopeRead}, Domains: []string{"ulmart.ru"}}
+	unrestricted := &db.APIKey{ID: "2", Scopes: []string{db.ScopeRead, db.ScopeWrite}}
+
+	tests := []struct {
+		name         string
+		handler      http.Handler
+		key          *db.APIKey
+		domain       string
+		expectedCode int
+		expectedBody string
+	}{
+		{"no key", RequireScope(db.ScopeWrite)(ok), nil, "", http.StatusOK, ""},
+		{"scope granted", RequireScope(db.ScopeRead)(ok), restricted, "", http.StatusOK, ""},
+		{"scope missing", RequireScope(db.ScopeWrite)(ok), restricted, "", http.StatusForbidden,
+			`{"error":"API key has no 'write' scope"}`},
+		{"domain allowed", RequireDomainAccess(ok), restricted, "ulmart.ru", http.StatusOK, ""},
+		{"domain denied", RequireDomainAccess(ok), restricted, "fidel.net", http.StatusForbidden,
+			`{"error":"API key has no access to domain 'fidel.net'"}`},
+		{"any domain", RequireDomainAccess(ok), unrestricted, "fidel.net", http.StatusOK, ""},
+		{"all domains denied", RequireAllDomains(ok), restricted, "", http.StatusForbidden,
+			`{"error":"API key is restricted to a list of domains"}`},
+		{"all domains allowed", RequireAllDomains(ok), unrestricted, "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		ctx := context.WithValue(context.Background(), ctxPrincipal, &principal{apiKey: tt.key})
+		ctx = context.WithValue(ctx, ctxDomainName, tt.domain)
+		r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		tt.handler.ServeHTTP(w, r)
+
+		assert.Equal(t, tt.expectedCode, w.Code, tt.name)
+		assert.Equal(t, tt.expectedBody, strings.TrimSpace(w.Body.String()), tt.name)
+	}
+}
@@ -0,0 +1,142 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"go.uber.org/zap"
+)
+
+const changesURL = "/changes"
+
+// parseChangesOpts parses and validates dates position changes are selected between.
+func parseChangesOpts(domain string, query url.Values) (*db.GetChangesOpts, error) {
+	from, err := parseDateParam(query, fromParam)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseDateParam(query, toParam)
+	if err != nil {
+		return nil, err
+	}
+	if from == nil || to == nil {
+		return nil, fmt.Errorf("both '%s' and '%s' are required", fromParam, toParam)
+	}
+	if from.After(*to) {
+		return nil, fmt.Errorf("'%s' must not be after '%s'", fromParam, toParam)
+	}
+
+	return &db.GetChangesOpts{Domain: domain, From: *from, To: *to}, nil
+}
+
+func changesHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log, err := GetContextLogger(req.Context())
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+		domain := GetDomainName(req.Context())
+
+		opts, err := parseChangesOpts(domain, req.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		limit, err := parseLimit(req.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		pageNum, err := parsePage(req.URL.Query(), limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		// Cursor continues the previous page and takes precedence over page number
+		opts.After, err = parseCursor(req.URL.Query(), db.ChangesOrderBy)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+		if opts.After != nil {
+			pageNum = 0
+		}
+
+		// An extra change tells whether there is a next page
+		opts.Limit = limit + 1
+		opts.Offset = limit * (pageNum - 1)
+
+		repo := newRepository(log, b)
+		changes, err := repo.GetChanges(req.Context(), opts)
+		if err != nil {
+			log.Error("failed to get position changes", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		// Counts cover all pages
+		counts, err := repo.CountChanges(req.Context(), opts)
+		if err != nil {
+			log.Error("failed to count position changes", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+		var total int
+		for _, count := range counts {
+			total += count
+		}
+
+		var nextCursor string
+		hasMore := len(changes) > limit
+		if hasMore {
+			changes = changes[:limit]
+			nextCursor = db.NewChangesCursor(changes[len(changes)-1]).Encode()
+		}
+
+		meta := NewPaginationMeta(total, pageNum, limit, hasMore)
+		setLinkHeader(w, req, meta, nextCursor)
+
+		meterRows(req.Context(), len(changes))
+		w.WriteHeader(http.StatusOK)
+		JSON(w, NewChangesResponse(opts, counts, changes, nextCursor, meta))
+	}
+}
+
+func NewChangesResponse(opts *db.GetChangesOpts, counts map[string]int, changes []*db.PositionChange,
+	nextCursor string, meta *PaginationMeta) interface{} {
+	return struct {
+		Domain     string               `json:"domain"`
+		From       string               `json:"from"`
+		To         string               `json:"to"`
+		Counts     map[string]int       `json:"counts"`
+		Changes    []*db.PositionChange `json:"changes"`
+		NextCursor string               `json:"next_cursor,omitempty"`
+		Meta       *PaginationMeta      `json:"meta"`
+	}{
+		Domain:     opts.Domain,
+		From:       opts.From.Format(db.UpdatedLayout),
+		To:         opts.To.Format(db.UpdatedLayout),
+		Counts:     counts,
+		Changes:    changes,
+		NextCursor: nextCursor,
+		Meta:       meta,
+	}
+}
@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"go.uber.org/zap"
+)
+
+const (
+	compareURL = "/compare"
+
+	domainsParam = "domains"
+	modeParam    = "mode"
+
+	maxComparedDomains = 10
+)
+
+var validCompareModes = map[string]struct{}{
+	db.CompareModeAll:     {},
+	db.CompareModeMissing: {},
+	db.CompareModeWeak:    {},
+	db.CompareModeShared:  {},
+}
+
+// parseCompareDomainsOpts parses and validates compared domains, mode and filter.
+func parseCompareDomainsOpts(query url.Values) (*db.CompareDomainsOpts, error) {
+	raw := query.Get(domainsParam)
+	if raw == "" {
+		return nil, fmt.Errorf("'%s' is required", domainsParam)
+	}
+
+	domains := strings.Split(raw, ",")
+	if len(domains) < 2 || len(domains) > maxComparedDomains {
+		return nil, fmt.Errorf("'%s' must contain from 2 to %d domains", domainsParam, maxComparedDomains)
+	}
+
+	seen := make(map[string]struct{}, len(domains))
+	for i, domain := range domains {
+		domain = strings.TrimSpace(domain)
+		if domain == "" {
+			return nil, fmt.Errorf("'%s' must not contain empty domains", domainsParam)
+		}
+		if _, ok := seen[domain]; ok {
+			return nil, fmt.Errorf("'%s' contains '%s' domain twice", domainsParam, domain)
+		}
+		seen[domain] = struct{}{}
+		domains[i] = domain
+	}
+
+	mode := query.Get(modeParam)
+	if mode == "" {
+		mode = db.CompareModeAll
+	}
+	if _, ok := validCompareModes[mode]; !ok {
+		return nil, fmt.Errorf("'%s' must be one of: all, missing, weak, shared", modeParam)
+	}
+
+	filter, err := parsePositionsFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keywords are paginated by page number only, a cursor mustn't silently restart the selection
+	if query.Get(cursorParam) != "" {
+		return nil, fmt.Errorf("'%s' isn't supported by comparison, use '%s'", cursorParam, pageParam)
+	}
+
+	return &db.CompareDomainsOpts{Domains: domains, Mode: mode, Filter: filter}, nil
+}
+
+func compareHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log, err := GetContextLogger(req.Context())
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		opts, err := parseCompareDomainsOpts(req.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+		if !checkDomainsAccess(w, req, opts.Domains...) {
+			return
+		}
+
+		limit, err := parseLimit(req.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		pageNum, err := parsePage(req.URL.Query(), limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		// An extra keyword tells whether there is a next page
+		opts.Limit = limit + 1
+		opts.Offset = limit * (pageNum - 1)
+
+		repo := newRepository(log, b)
+		keywords, err := repo.CompareDomains(req.Context(), opts)
+		if err != nil {
+			log.Error("failed to compare domains", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		total, err := repo.CountComparedKeywords(req.Context(), opts)
+		if err != nil {
+			log.Error("failed to count compared keywords", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		hasMore := len(keywords) > limit
+		if hasMore {
+			keywords = keywords[:limit]
+		}
+
+		meta := NewPaginationMeta(total, pageNum, limit, hasMore)
+		setLinkHeader(w, req, meta, "")
+
+		meterRows(req.Context(), len(keywords))
+		w.WriteHeader(http.StatusOK)
+		JSON(w, NewCompareResponse(opts, keywords, meta))
+	}
+}
+
+func NewCompareResponse(opts *db.CompareDomainsOpts, keywords []*db.KeywordComparison, meta *PaginationMeta) interface{} {
+	return struct {
+		Domains  []string                `json:"domains"`
+		Mode     string                  `json:"mode"`
+		Keywords []*db.KeywordComparison `json:"keywords"`
+		Meta     *PaginationMeta         `json:"meta"`
+	}{Domains: opts.Domains, Mode: opts.Mode, Keywords: keywords, Meta: meta}
+}
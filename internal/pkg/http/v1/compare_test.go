@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCompareDomainsOpts(t *testing.T) {
+	query, err := url.ParseQuery("domains=fidel.net, oriental.com&mode=missing&maxPosition=10")
+	assert.NoError(t, err)
+
+	maxPosition := 10
+
+	got, err := parseCompareDomainsOpts(query)
+	assert.NoError(t, err)
+	assert.Equal(t, &db.CompareDomainsOpts{
+		Domains: []string{"fidel.net", "oriental.com"},
+		Mode:    db.CompareModeMissing,
+		Filter:  &db.PositionsFilter{MaxPosition: &maxPosition},
+	}, got)
+
+	// All keywords are compared by default
+	query, err = url.ParseQuery("domains=fidel.net,oriental.com")
+	assert.NoError(t, err)
+
+	got, err = parseCompareDomainsOpts(query)
+	assert.NoError(t, err)
+	assert.Equal(t, db.CompareModeAll, got.Mode)
+}
+
+func TestParseCompareDomainsOptsErrors(t *testing.T) {
+	tests := map[string]string{
+		"":                                "'domains' is required",
+		"domains=fidel.net":               "'domains' must contain from 2 to 10 domains",
+		"domains=a,b,c,d,e,f,g,h,i,j,k":   "'domains' must contain from 2 to 10 domains",
+		"domains=fidel.net,,oriental.com": "'domains' must not contain empty domains",
+		"domains=fidel.net,oriental.com,fidel.net":    "'domains' contains 'fidel.net' domain twice",
+		"domains=fidel.net,oriental.com&mode=lost":    "'mode' must be one of: all, missing, weak, shared",
+		"domains=fidel.net,oriental.com&minVolume=-1": "'minVolume' must be a non-negative integer",
+		"domains=fidel.net,oriental.com&cursor=abc":   "'cursor' isn't supported by comparison, use 'page'",
+	}
+
+	for rawQuery, expected := range tests {
+		query, err := url.ParseQuery(rawQuery)
+		assert.NoError(t, err)
+
+		_, err = parseCompareDomainsOpts(query)
+		assert.EqualError(t, err, expected, rawQuery)
+	}
+}
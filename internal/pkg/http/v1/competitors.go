@@ -0,0 +1,81 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"go.uber.org/zap"
+)
+
+const competitorsURL = "/competitors"
+
+func competitorsHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log, err := GetContextLogger(req.Context())
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+		domain := GetDomainName(req.Context())
+
+		limit, err := parseLimit(req.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		pageNum, err := parsePage(req.URL.Query(), limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		// An extra competitor tells whether there is a next page
+		repo := newRepository(log, b)
+		competitors, err := repo.GetCompetitors(req.Context(), &db.GetCompetitorsOpts{
+			Domain: domain,
+			Limit:  limit + 1,
+			Offset: limit * (pageNum - 1),
+		})
+		if err != nil {
+			log.Error("failed to get competitors", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		total, err := repo.CountCompetitors(req.Context(), domain)
+		if err != nil {
+			log.Error("failed to count competitors", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		hasMore := len(competitors) > limit
+		if hasMore {
+			competitors = competitors[:limit]
+		}
+
+		meta := NewPaginationMeta(total, pageNum, limit, hasMore)
+		setLinkHeader(w, req, meta, "")
+
+		meterRows(req.Context(), len(competitors))
+		w.WriteHeader(http.StatusOK)
+		JSON(w, NewCompetitorsResponse(domain, competitors, meta))
+	}
+}
+
+func NewCompetitorsResponse(domain string, competitors []*db.Competitor, meta *PaginationMeta) interface{} {
+	return struct {
+		Domain      string           `json:"domain"`
+		Competitors []*db.Competitor `json:"competitors"`
+		Meta        *PaginationMeta  `json:"meta"`
+	}{Domain: domain, Competitors: competitors, Meta: meta}
+}
@@ -0,0 +1,287 @@
+package v1
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+)
+
+const (
+	AcceptEncodingHeader  = "accept-encoding"
+	ContentEncodingHeader = "content-encoding"
+	VaryHeader            = "vary"
+
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// supportedEncodings contains content codings in order of preference.
+var supportedEncodings = []string{encodingBrotli, encodingGzip}
+
+// encoder is a compressing writer which can be reused for another response.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// responseSize is a number of body bytes written by handlers, it differs from a number of
+// bytes sent to the client if the response is compressed.
+type responseSize struct {
+	uncompressed int
+	compressed   bool
+}
+
+// Compress middleware compresses responses with brotli or gzip, whichever is preferred by
+// Accept-Encoding of the request. Responses shorter than the min size are sent as is.
+// Strong ETags of compressed responses get a suffix of the encoding, so that they differ
+// from ETags of the same representation sent as is.
+func Compress(cfg config.CompressionConfig) func(next http.Handler) http.Handler {
+	gzipLevel := cfg.GzipLevel
+	if gzipLevel <= 0 {
+		gzipLevel = gzip.DefaultCompression
+	}
+	brotliLevel := cfg.BrotliLevel
+	if brotliLevel <= 0 {
+		brotliLevel = brotli.DefaultCompression
+	}
+
+	pools := map[string]*sync.Pool{
+		encodingGzip: {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(ioutil.Discard, gzipLevel)
+
+			return w
+		}},
+		encodingBrotli: {New: func() interface{} {
+			return brotli.NewWriterLevel(ioutil.Discard, brotliLevel)
+		}},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add(VaryHeader, "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get(AcceptEncodingHeader))
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				pool:           pools[encoding],
+				minSize:        cfg.MinSize,
+			}
+
+			// Conditional requests are evaluated against ETags of uncompressed responses
+			if ifNoneMatch := r.Header.Get(IfNoneMatchHeader); ifNoneMatch != "" {
+				if stripped, ok := stripETagsEncoding(ifNoneMatch, encoding); ok {
+					r = r.Clone(r.Context())
+					r.Header.Set(IfNoneMatchHeader, stripped)
+					cw.strippedETag = true
+				}
+			}
+
+			next.ServeHTTP(cw, r)
+
+			_ = cw.close()
+
+			if size, ok := r.Context().Value(ctxResponseSize).(*responseSize); ok {
+				size.uncompressed = cw.written
+				size.compressed = cw.encoder != nil
+			}
+		})
+	}
+}
+
+// compressWriter buffers the beginning of a response until it's known whether the response
+// reaches the min size and then sends the rest either compressed or as is.
+type compressWriter struct {
+	http.ResponseWriter
+
+	encoding string
+	pool     *sync.Pool
+	minSize  int
+	// strippedETag is set if the request was made with ETags of the compressed response.
+	strippedETag bool
+
+	status  int
+	buf     []byte
+	started bool
+	// encoder is nil if the response is sent as is.
+	encoder encoder
+	// written is a number of uncompressed body bytes.
+	written int
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	cw.WriteHeader(http.StatusOK)
+	cw.written += len(p)
+
+	if !cw.started {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+
+		return len(p), nil
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush sends buffered data to the client once the response is started, a response shorter
+// than the min size is kept until it's complete.
+func (cw *compressWriter) Flush() {
+	if !cw.started {
+		return
+	}
+	if cw.encoder != nil {
+		if err := cw.encoder.Flush(); err != nil {
+			return
+		}
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// start sends headers and the buffered beginning of the response, it's compressed if compress
+// is set and the status allows a body.
+func (cw *compressWriter) start(compress bool) error {
+	cw.started = true
+
+	h := cw.Header()
+	compress = compress && h.Get(ContentEncodingHeader) == "" && cw.status >= http.StatusOK &&
+		cw.status != http.StatusNoContent && cw.status != http.StatusPartialContent && cw.status != http.StatusNotModified
+	if compress {
+		h.Set(ContentEncodingHeader, cw.encoding)
+		h.Del("Content-Length")
+
+		cw.encoder = cw.pool.Get().(encoder)
+		cw.encoder.Reset(cw.ResponseWriter)
+	}
+	if compress || (cw.status == http.StatusNotModified && cw.strippedETag) {
+		if etag := h.Get(ETagHeader); strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`) {
+			h.Set(ETagHeader, strings.TrimSuffix(etag, `"`)+"-"+cw.encoding+`"`)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.encoder != nil {
+		_, err := cw.encoder.Write(buf)
+
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+
+	return err
+}
+
+// close completes the response, an incomplete one is sent as is.
+func (cw *compressWriter) close() error {
+	if !cw.started && cw.status != 0 {
+		if err := cw.start(false); err != nil {
+			return err
+		}
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	cw.encoder.Reset(ioutil.Discard)
+	cw.pool.Put(cw.encoder)
+
+	return err
+}
+
+// negotiateEncoding returns the most preferred supported encoding of Accept-Encoding,
+// empty string means the response has to be sent as is.
+func negotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+		qualities[coding] = quality
+	}
+
+	var (
+		best        string
+		bestQuality float64
+	)
+	for _, encoding := range supportedEncodings {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+		if ok && quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+
+	return best
+}
+
+// stripETagsEncoding removes the encoding suffix from entity tags of If-None-Match, it reports
+// whether any of them had it.
+func stripETagsEncoding(ifNoneMatch, encoding string) (string, bool) {
+	suffix := "-" + encoding + `"`
+
+	var stripped bool
+	candidates := strings.Split(ifNoneMatch, ",")
+	for i, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasSuffix(candidate, suffix) {
+			candidate = strings.TrimSuffix(candidate, suffix) + `"`
+			stripped = true
+		}
+		candidates[i] = candidate
+	}
+
+	return strings.Join(candidates, ", "), stripped
+}
@@ -0,0 +1,131 @@
+package v1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"GZIP", "gzip"},
+		{"*", "br"},
+		{"br;q=0, *", "gzip"},
+		{"deflate", ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, negotiateEncoding(test.acceptEncoding), test.acceptEncoding)
+	}
+}
+
+func TestStripETagsEncoding(t *testing.T) {
+	stripped, ok := stripETagsEncoding(`"abc-br", "xyz"`, encodingBrotli)
+	assert.True(t, ok)
+	assert.Equal(t, `"abc", "xyz"`, stripped)
+
+	_, ok = stripETagsEncoding(`"abc-gzip"`, encodingBrotli)
+	assert.False(t, ok)
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"keyword":"test","url":"http://ulmart.ru/test"}`, 100)
+
+	tests := []struct {
+		name             string
+		acceptEncoding   string
+		body             string
+		expectedEncoding string
+	}{
+		{"gzip", "gzip", large, "gzip"},
+		{"brotli", "gzip, br", large, "br"},
+		{"identity", "", large, ""},
+		{"below min size", "gzip, br", "{}", ""},
+	}
+	for _, test := range tests {
+		handler := Compress(config.CompressionConfig{MinSize: 1024})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(ETagHeader, `"abc"`)
+			w.WriteHeader(http.StatusOK)
+			// Written in parts to check buffering up to the min size
+			_, _ = w.Write([]byte(test.body[:len(test.body)/2]))
+			_, _ = w.Write([]byte(test.body[len(test.body)/2:]))
+		}))
+
+		size := &responseSize{}
+		r, err := http.NewRequestWithContext(context.WithValue(context.Background(), ctxResponseSize, size), http.MethodGet, "/", nil)
+		assert.NoError(t, err)
+		r.Header.Set(AcceptEncodingHeader, test.acceptEncoding)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code, test.name)
+		assert.Equal(t, "Accept-Encoding", w.Header().Get(VaryHeader), test.name)
+		assert.Equal(t, test.expectedEncoding, w.Header().Get(ContentEncodingHeader), test.name)
+
+		body := w.Body.Bytes()
+		switch test.expectedEncoding {
+		case encodingGzip:
+			gr, err := gzip.NewReader(bytes.NewReader(body))
+			assert.NoError(t, err, test.name)
+			body, err = ioutil.ReadAll(gr)
+			assert.NoError(t, err, test.name)
+		case encodingBrotli:
+			body, err = ioutil.ReadAll(brotli.NewReader(bytes.NewReader(body)))
+			assert.NoError(t, err, test.name)
+		}
+		assert.Equal(t, test.body, string(body), test.name)
+
+		if test.expectedEncoding != "" {
+			assert.Equal(t, `"abc-`+test.expectedEncoding+`"`, w.Header().Get(ETagHeader), test.name)
+			assert.True(t, size.compressed, test.name)
+			assert.Equal(t, len(test.body), size.uncompressed, test.name)
+			assert.Less(t, w.Body.Len(), len(test.body), test.name)
+		} else {
+			assert.Equal(t, `"abc"`, w.Header().Get(ETagHeader), test.name)
+			assert.False(t, size.compressed, test.name)
+		}
+	}
+}
+
+func TestCompress_NotModified(t *testing.T) {
+	handler := Compress(config.CompressionConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(ETagHeader, `"abc"`)
+		if r.Header.Get(IfNoneMatchHeader) == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+		_, _ = w.Write([]byte("body"))
+	}))
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	assert.NoError(t, err)
+	r.Header.Set(AcceptEncodingHeader, "gzip")
+	r.Header.Set(IfNoneMatchHeader, `"abc-gzip"`)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Empty(t, w.Header().Get(ContentEncodingHeader))
+	assert.Equal(t, `"abc-gzip"`, w.Header().Get(ETagHeader))
+}
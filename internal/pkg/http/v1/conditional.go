@@ -0,0 +1,176 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"go.uber.org/zap"
+)
+
+const (
+	ETagHeader            = "etag"
+	LastModifiedHeader    = "last-modified"
+	CacheControlHeader    = "cache-control"
+	IfNoneMatchHeader     = "if-none-match"
+	IfModifiedSinceHeader = "if-modified-since"
+)
+
+// bufferedResponseWriter keeps a response in memory until it's known whether it has to be sent.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferedResponseWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+func (bw *bufferedResponseWriter) Write(p []byte) (int, error) {
+	bw.WriteHeader(http.StatusOK)
+
+	return bw.body.Write(p)
+}
+
+// ConditionalGet middleware sets ETag, Last-Modified and Cache-Control headers of successful
+// responses of the route and replies with 304 Not Modified to conditional requests whose
+// representation hasn't changed. Last-Modified is the time of the latest write of the domain's
+// positions, or of any positions for routes without a domain.
+func ConditionalGet(b *backend.Backend, route string) func(next http.Handler) http.Handler {
+	return conditionalGet(b, route, false)
+}
+
+// ConditionalGetAllDomains is ConditionalGet of routes whose responses depend on positions of
+// other domains than the one in the path, Last-Modified is the time of the latest write of any positions.
+func ConditionalGetAllDomains(b *backend.Backend, route string) func(next http.Handler) http.Handler {
+	return conditionalGet(b, route, true)
+}
+
+func conditionalGet(b *backend.Backend, route string, allDomains bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bw := &bufferedResponseWriter{header: w.Header()}
+			next.ServeHTTP(bw, r)
+
+			if bw.status != http.StatusOK {
+				if bw.status != 0 {
+					w.WriteHeader(bw.status)
+				}
+				_, _ = w.Write(bw.body.Bytes())
+
+				return
+			}
+
+			etag := strongETag(bw.body.Bytes())
+			w.Header().Set(ETagHeader, etag)
+			w.Header().Set(CacheControlHeader, cacheControl(route))
+
+			// Empty domain stands for all domains
+			domain := GetDomainName(r.Context())
+			if allDomains {
+				domain = ""
+			}
+
+			var lastModified time.Time
+			if log, err := GetContextLogger(r.Context()); err == nil {
+				version, err := newRepository(log, b).GetDatasetVersion(r.Context(), domain)
+				if err != nil {
+					log.Warn("failed to get last modified time", zap.Error(err))
+				} else {
+					lastModified = version.Modified
+				}
+			}
+			if !lastModified.IsZero() {
+				w.Header().Set(LastModifiedHeader, lastModified.Format(http.TimeFormat))
+			}
+
+			if notModified(r, etag, lastModified) {
+				w.Header().Del("Content-Type")
+				w.Header().Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(bw.body.Bytes())
+		})
+	}
+}
+
+// strongETag returns an entity tag identifying the body byte for byte.
+func strongETag(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// cacheControl returns a value of Cache-Control header of the route's responses, they're
+// private if authentication is enabled, so that shared caches don't serve them to other clients.
+func cacheControl(route string) string {
+	var (
+		maxAge  int
+		private bool
+	)
+	if config.Config != nil {
+		var ok bool
+		if maxAge, ok = config.Config.PublicAPI.MaxAge[strings.TrimPrefix(route, "/")]; !ok {
+			maxAge = config.Config.PublicAPI.DefaultMaxAge
+		}
+		private = config.Config.PublicAPI.Auth.Enabled
+	}
+
+	directive := "no-cache"
+	if maxAge > 0 {
+		directive = "max-age=" + strconv.Itoa(maxAge)
+	}
+	if private {
+		return "private, " + directive
+	}
+
+	return directive
+}
+
+// notModified evaluates conditional headers of the request, If-Modified-Since is ignored
+// if If-None-Match is present (RFC 7232, section 6).
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get(IfNoneMatchHeader); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+	ifModifiedSince, err := http.ParseTime(r.Header.Get(IfModifiedSinceHeader))
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(ifModifiedSince)
+}
+
+// etagMatches reports whether any entity tag of If-None-Match matches the etag, weak
+// comparison is used as required for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETagMatches(t *testing.T) {
+	etag := `"abc"`
+
+	tests := []struct {
+		ifNoneMatch string
+		expected    bool
+	}{
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`*`, true},
+		{`"xyz"`, false},
+		{`abc`, false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, etagMatches(test.ifNoneMatch, etag), test.ifNoneMatch)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Date(2020, 12, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		lastModified time.Time
+		expected     bool
+	}{
+		{"no conditions", nil, lastModified, false},
+		{"etag matches", map[string]string{IfNoneMatchHeader: `"abc"`}, lastModified, true},
+		{
+			"etag takes precedence",
+			map[string]string{IfNoneMatchHeader: `"xyz"`, IfModifiedSinceHeader: lastModified.Format(http.TimeFormat)},
+			lastModified,
+			false,
+		},
+		{"not modified since", map[string]string{IfModifiedSinceHeader: lastModified.Format(http.TimeFormat)}, lastModified, true},
+		{
+			"modified since",
+			map[string]string{IfModifiedSinceHeader: lastModified.Add(-time.Second).Format(http.TimeFormat)},
+			lastModified,
+			false,
+		},
+		{"unknown modification time", map[string]string{IfModifiedSinceHeader: lastModified.Format(http.TimeFormat)}, time.Time{}, false},
+		{"invalid date", map[string]string{IfModifiedSinceHeader: "yesterday"}, lastModified, false},
+	}
+	for _, test := range tests {
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		assert.NoError(t, err)
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+
+		assert.Equal(t, test.expected, notModified(r, `"abc"`, test.lastModified), test.name)
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	config.Config = nil
+	defer func() { config.Config = nil }()
+	assert.Equal(t, "no-cache", cacheControl(summaryURL))
+
+	config.Config = &config.AppConfig{
+		PublicAPI: config.PublicAPIServerConfig{
+			DefaultMaxAge: 60,
+			MaxAge:        map[string]int{"summary": 300, "search": 0},
+		},
+	}
+	assert.Equal(t, "max-age=300", cacheControl(summaryURL))
+	assert.Equal(t, "no-cache", cacheControl(searchURL))
+	assert.Equal(t, "max-age=60", cacheControl(positionsURL))
+
+	config.Config.PublicAPI.Auth.Enabled = true
+	assert.Equal(t, "private, max-age=300", cacheControl(summaryURL))
+	assert.Equal(t, "private, no-cache", cacheControl(searchURL))
+}
@@ -0,0 +1,123 @@
+package v1
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+
+	// exportFlushEvery is a number of positions after which the response is flushed to the client.
+	exportFlushEvery = 1000
+)
+
+var exportContentTypes = map[string]string{
+	exportFormatCSV:    "text/csv; charset=utf-8",
+	exportFormatNDJSON: "application/x-ndjson",
+}
+
+var exportCSVHeader = []string{"keyword", "position", "url", "volume", "results", "cpc", "updated"}
+
+// ConnContext saves a connection into the context of its requests, so that export can extend
+// the write deadline the server sets for every request. It's meant for http.Server.ConnContext.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, ctxConn, c)
+}
+
+// extendWriteDeadline replaces the write deadline of the request's connection, it's a no-op
+// if the connection isn't saved by ConnContext.
+func extendWriteDeadline(ctx context.Context, timeout time.Duration) error {
+	c, ok := ctx.Value(ctxConn).(net.Conn)
+	if !ok {
+		return nil
+	}
+
+	return c.SetWriteDeadline(time.Now().Add(timeout))
+}
+
+// positionsEncoder writes positions to the response in the export format.
+type positionsEncoder interface {
+	// Encode writes a single position.
+	Encode(p *db.Position) error
+	// Flush sends everything that has been buffered to the client.
+	Flush() error
+}
+
+// newPositionsEncoder starts the export response and returns an encoder for its body.
+func newPositionsEncoder(w http.ResponseWriter, domain, format string) (positionsEncoder, error) {
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", domain+"."+format))
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+
+	if format == exportFormatNDJSON {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(true)
+
+		return &ndjsonEncoder{enc: enc, flusher: flusher}, nil
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportCSVHeader); err != nil {
+		return nil, err
+	}
+
+	return &csvEncoder{w: cw, flusher: flusher}, nil
+}
+
+type csvEncoder struct {
+	w       *csv.Writer
+	flusher http.Flusher
+}
+
+func (e *csvEncoder) Encode(p *db.Position) error {
+	return e.w.Write([]string{
+		p.Keyword,
+		strconv.Itoa(p.Position),
+		p.URL,
+		strconv.Itoa(p.Volume),
+		strconv.Itoa(p.Results),
+		strconv.FormatFloat(p.CPC, 'f', -1, 64),
+		p.Updated,
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	if err := e.w.Error(); err != nil {
+		return err
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+
+	return nil
+}
+
+type ndjsonEncoder struct {
+	enc     *json.Encoder
+	flusher http.Flusher
+}
+
+func (e *ndjsonEncoder) Encode(p *db.Position) error {
+	return e.enc.Encode(p)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package v1
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtendWriteDeadline(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/export" {
+			assert.NoError(t, extendWriteDeadline(r.Context(), 5*time.Second))
+		}
+
+		// The response is written after the server's write timeout
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	}))
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Config.ConnContext = ConnContext
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/export")
+	assert.NoError(t, err)
+	if err == nil {
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "done", string(body))
+		_ = resp.Body.Close()
+	}
+
+	// The rest of responses are cut off
+	_, err = http.Get(srv.URL + "/positions")
+	assert.Error(t, err)
+}
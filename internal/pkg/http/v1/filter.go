@@ -0,0 +1,140 @@
+package v1
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+)
+
+// Query parameters to filter positions by.
+const (
+	minPositionParam     = "minPosition"
+	maxPositionParam     = "maxPosition"
+	minVolumeParam       = "minVolume"
+	maxVolumeParam       = "maxVolume"
+	minResultsParam      = "minResults"
+	maxResultsParam      = "maxResults"
+	minCPCParam          = "minCpc"
+	maxCPCParam          = "maxCpc"
+	updatedFromParam     = "updatedFrom"
+	updatedToParam       = "updatedTo"
+	keywordPrefixParam   = "keywordPrefix"
+	keywordContainsParam = "keywordContains"
+	urlPrefixParam       = "urlPrefix"
+	urlContainsParam     = "urlContains"
+
+	// maxMatchLength limits a length of keyword and url patterns.
+	maxMatchLength = 256
+)
+
+// parsePositionsFilter parses and validates filter query parameters.
+func parsePositionsFilter(query url.Values) (*db.PositionsFilter, error) {
+	f := &db.PositionsFilter{}
+
+	intRanges := []struct {
+		minParam, maxParam string
+		min, max           **int
+	}{
+		{minPositionParam, maxPositionParam, &f.MinPosition, &f.MaxPosition},
+		{minVolumeParam, maxVolumeParam, &f.MinVolume, &f.MaxVolume},
+		{minResultsParam, maxResultsParam, &f.MinResults, &f.MaxResults},
+	}
+	for _, r := range intRanges {
+		var err error
+		if *r.min, err = parseIntParam(query, r.minParam); err != nil {
+			return nil, err
+		}
+		if *r.max, err = parseIntParam(query, r.maxParam); err != nil {
+			return nil, err
+		}
+		if *r.min != nil && *r.max != nil && **r.min > **r.max {
+			return nil, fmt.Errorf("'%s' must not be greater than '%s'", r.minParam, r.maxParam)
+		}
+	}
+
+	var err error
+	if f.MinCPC, err = parseFloatParam(query, minCPCParam); err != nil {
+		return nil, err
+	}
+	if f.MaxCPC, err = parseFloatParam(query, maxCPCParam); err != nil {
+		return nil, err
+	}
+	if f.MinCPC != nil && f.MaxCPC != nil && *f.MinCPC > *f.MaxCPC {
+		return nil, fmt.Errorf("'%s' must not be greater than '%s'", minCPCParam, maxCPCParam)
+	}
+
+	if f.UpdatedFrom, err = parseDateParam(query, updatedFromParam); err != nil {
+		return nil, err
+	}
+	if f.UpdatedTo, err = parseDateParam(query, updatedToParam); err != nil {
+		return nil, err
+	}
+	if f.UpdatedFrom != nil && f.UpdatedTo != nil && f.UpdatedFrom.After(*f.UpdatedTo) {
+		return nil, fmt.Errorf("'%s' must not be after '%s'", updatedFromParam, updatedToParam)
+	}
+
+	matches := []struct {
+		param string
+		dst   *string
+	}{
+		{keywordPrefixParam, &f.KeywordPrefix},
+		{keywordContainsParam, &f.KeywordContains},
+		{urlPrefixParam, &f.URLPrefix},
+		{urlContainsParam, &f.URLContains},
+	}
+	for _, m := range matches {
+		value := query.Get(m.param)
+		if len(value) > maxMatchLength {
+			return nil, fmt.Errorf("'%s' must not be longer than %d characters", m.param, maxMatchLength)
+		}
+		*m.dst = value
+	}
+
+	return f, nil
+}
+
+func parseIntParam(query url.Values, param string) (*int, error) {
+	raw := query.Get(param)
+	if raw == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 {
+		return nil, fmt.Errorf("'%s' must be a non-negative integer", param)
+	}
+
+	return &v, nil
+}
+
+func parseFloatParam(query url.Values, param string) (*float64, error) {
+	raw := query.Get(param)
+	if raw == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("'%s' must be a non-negative number", param)
+	}
+
+	return &v, nil
+}
+
+func parseDateParam(query url.Values, param string) (*time.Time, error) {
+	raw := query.Get(param)
+	if raw == "" {
+		return nil, nil
+	}
+
+	v, err := time.Parse(db.UpdatedLayout, raw)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' must be a date in %s format", param, db.UpdatedLayout)
+	}
+
+	return &v, nil
+}
@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePositionsFilter(t *testing.T) {
+	query, err := url.ParseQuery("minPosition=1&maxPosition=10&minVolume=1000&maxCpc=2.5" +
+		"&updatedFrom=2017-05-15&keywordContains=shoe&urlPrefix=https://fidel.net/")
+	assert.NoError(t, err)
+
+	minPosition, maxPosition, minVolume := 1, 10, 1000
+	maxCPC := 2.5
+	updatedFrom := time.Date(2017, 5, 15, 0, 0, 0, 0, time.UTC)
+
+	got, err := parsePositionsFilter(query)
+	assert.NoError(t, err)
+	assert.Equal(t, &db.PositionsFilter{
+		MinPosition:     &minPosition,
+		MaxPosition:     &maxPosition,
+		MinVolume:       &minVolume,
+		MaxCPC:          &maxCPC,
+		UpdatedFrom:     &updatedFrom,
+		KeywordContains: "shoe",
+		URLPrefix:       "https://fidel.net/",
+	}, got)
+}
+
+func TestParsePositionsFilterErrors(t *testing.T) {
+	tests := map[string]string{
+		"minVolume=-1":                 "'minVolume' must be a non-negative integer",
+		"maxResults=many":              "'maxResults' must be a non-negative integer",
+		"minPosition=10&maxPosition=1": "'minPosition' must not be greater than 'maxPosition'",
+		"minCpc=NaN":                   "'minCpc' must be a non-negative number",
+		"minCpc=3&maxCpc=2":            "'minCpc' must not be greater than 'maxCpc'",
+		"updatedTo=20.05.2017":         "'updatedTo' must be a date in 2006-01-02 format",
+		"updatedFrom=2017-05-20&updatedTo=2017-05-15": "'updatedFrom' must not be after 'updatedTo'",
+	}
+
+	for rawQuery, expected := range tests {
+		query, err := url.ParseQuery(rawQuery)
+		assert.NoError(t, err)
+
+		_, err = parsePositionsFilter(query)
+		assert.EqualError(t, err, expected, rawQuery)
+	}
+}
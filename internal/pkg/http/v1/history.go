@@ -0,0 +1,93 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"go.uber.org/zap"
+)
+
+const (
+	historyURL = "/history"
+
+	keywordParam = "keyword"
+	urlParam     = "url"
+	fromParam    = "from"
+	toParam      = "to"
+)
+
+// parseHistoryOpts parses and validates query parameters of the domain's history selection.
+func parseHistoryOpts(domain string, query url.Values) (*db.GetHistoryOpts, error) {
+	opts := &db.GetHistoryOpts{
+		Domain:  domain,
+		Keyword: query.Get(keywordParam),
+		URL:     query.Get(urlParam),
+	}
+
+	// Whole history of a domain may be huge
+	if opts.Keyword == "" && opts.URL == "" {
+		return nil, fmt.Errorf("either '%s' or '%s' is required", keywordParam, urlParam)
+	}
+	for _, v := range []string{opts.Keyword, opts.URL} {
+		if len(v) > maxMatchLength {
+			return nil, fmt.Errorf("'%s' and '%s' must not be longer than %d characters",
+				keywordParam, urlParam, maxMatchLength)
+		}
+	}
+
+	var err error
+	if opts.From, err = parseDateParam(query, fromParam); err != nil {
+		return nil, err
+	}
+	if opts.To, err = parseDateParam(query, toParam); err != nil {
+		return nil, err
+	}
+	if opts.From != nil && opts.To != nil && opts.From.After(*opts.To) {
+		return nil, fmt.Errorf("'%s' must not be after '%s'", fromParam, toParam)
+	}
+
+	return opts, nil
+}
+
+func historyHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log, err := GetContextLogger(req.Context())
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+		domain := GetDomainName(req.Context())
+
+		opts, err := parseHistoryOpts(domain, req.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		repo := newRepository(log, b)
+		history, err := repo.GetHistory(req.Context(), opts)
+		if err != nil {
+			log.Error("failed to get history", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		meterRows(req.Context(), len(history))
+		w.WriteHeader(http.StatusOK)
+		JSON(w, NewHistoryResponse(domain, history))
+	}
+}
+
+func NewHistoryResponse(domain string, history []*db.PositionHistory) interface{} {
+	return struct {
+		Domain  string                `json:"domain"`
+		History []*db.PositionHistory `json:"history"`
+	}{Domain: domain, History: history}
+}
@@ -0,0 +1,46 @@
+package v1
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHistoryOpts(t *testing.T) {
+	query, err := url.ParseQuery("keyword=shoes&from=2017-05-15&to=2017-05-20")
+	assert.NoError(t, err)
+
+	from := time.Date(2017, 5, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2017, 5, 20, 0, 0, 0, 0, time.UTC)
+
+	got, err := parseHistoryOpts("fidel.net", query)
+	assert.NoError(t, err)
+	assert.Equal(t, &db.GetHistoryOpts{
+		Domain:  "fidel.net",
+		Keyword: "shoes",
+		From:    &from,
+		To:      &to,
+	}, got)
+}
+
+func TestParseHistoryOptsErrors(t *testing.T) {
+	tests := map[string]string{
+		"":                                "either 'keyword' or 'url' is required",
+		"from=2017-05-15":                 "either 'keyword' or 'url' is required",
+		"url=" + strings.Repeat("a", 257): "'keyword' and 'url' must not be longer than 256 characters",
+		"keyword=shoes&to=20.05.2017":     "'to' must be a date in 2006-01-02 format",
+		"keyword=shoes&from=2017-05-20&to=2017-05-15": "'from' must not be after 'to'",
+	}
+
+	for rawQuery, expected := range tests {
+		query, err := url.ParseQuery(rawQuery)
+		assert.NoError(t, err)
+
+		_, err = parseHistoryOpts("fidel.net", query)
+		assert.EqualError(t, err, expected, rawQuery)
+	}
+}
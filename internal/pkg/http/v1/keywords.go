@@ -0,0 +1,42 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"go.uber.org/zap"
+)
+
+const keywordsURL = "/keywords"
+
+func keywordHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log, err := GetContextLogger(req.Context())
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		repo := newRepository(log, b)
+		keyword, err := repo.GetKeyword(req.Context(), GetKeyword(req.Context()))
+		if err != nil {
+			if errors.Is(err, db.ErrKeywordNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				JSON(w, map[string]string{"error": err.Error()})
+
+				return
+			}
+			log.Error("failed to get keyword", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		meterRows(req.Context(), len(keyword.Rankings))
+		w.WriteHeader(http.StatusOK)
+		JSON(w, keyword)
+	}
+}
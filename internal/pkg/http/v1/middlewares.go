@@ -1,12 +1,12 @@
 package v1
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -26,15 +26,24 @@ const (
 )
 
 const (
-	domainNameParam = "domain_name"
+	domainNameParam  = "domain_name"
+	keywordNameParam = "keyword_name"
 )
 
+// maxLoggedBodySize limits a number of request body bytes written to the access log.
+const maxLoggedBodySize = 1 << 10
+
 type ctxKey int
 
 const (
 	ctxRequestID ctxKey = iota
 	ctxLogger
 	ctxDomainName
+	ctxKeyword
+	ctxResponseSize
+	ctxPrincipal
+	ctxUsage
+	ctxConn
 )
 
 // SetRequestID middleware creates a new request ID and saves it into request context.
@@ -80,22 +89,25 @@ func RequestLogger(log *zap.Logger) func(next http.Handler) http.Handler {
 			userAgent := r.Header.Get(UserAgentHeader)
 			referer := r.Header.Get(RefererHeader)
 
-			// Retrieve request body contents
-			var bodyBytes []byte
+			// Only the beginning of the request body read by handlers is logged
+			body := &bodyRecorder{}
 			if r.Body != nil {
-				bodyBytes, _ = ioutil.ReadAll(r.Body)
+				body.ReadCloser = r.Body
+				r.Body = body
 			}
 
-			// Restore the io.ReadCloser to its original state
-			r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
-
-			bodyString := string(bodyBytes)
-
 			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
 			// Save start time
 			start := time.Now().UTC()
 
+			// Compress middleware reports a size of the response before compression
+			size := &responseSize{}
+			ctx := context.WithValue(r.Context(), ctxResponseSize, size)
+			// Authenticate middleware sets the client
+			p := &principal{}
+			r = r.WithContext(context.WithValue(ctx, ctxPrincipal, p))
+
 			// Proceed to next middleware
 			next.ServeHTTP(ww, r)
 
@@ -104,6 +116,11 @@ func RequestLogger(log *zap.Logger) func(next http.Handler) http.Handler {
 				statusCode := ww.Status()
 				msg := "Request completed"
 
+				uncompressedBytes := ww.BytesWritten()
+				if size.compressed {
+					uncompressedBytes = size.uncompressed
+				}
+
 				fields := []zapcore.Field{
 					zap.Int("status", statusCode),
 					zap.Duration("latency", latency),
@@ -113,8 +130,12 @@ func RequestLogger(log *zap.Logger) func(next http.Handler) http.Handler {
 					zap.String(UserAgentHeader, userAgent),
 					zap.String(RefererHeader, referer),
 					zap.String(RequestIDHeader, requestID),
-					zap.String("request_body", bodyString),
+					zap.String("request_body", string(body.head)),
 					zap.Int("response_bytes_written", ww.BytesWritten()),
+					zap.Int("response_bytes_uncompressed", uncompressedBytes),
+				}
+				if p.apiKey != nil {
+					fields = append(fields, principalFields(p.apiKey)...)
 				}
 
 				switch {
@@ -130,6 +151,24 @@ func RequestLogger(log *zap.Logger) func(next http.Handler) http.Handler {
 	}
 }
 
+// bodyRecorder keeps the beginning of a request body read by handlers.
+type bodyRecorder struct {
+	io.ReadCloser
+	head []byte
+}
+
+func (br *bodyRecorder) Read(p []byte) (int, error) {
+	n, err := br.ReadCloser.Read(p)
+	if rest := maxLoggedBodySize - len(br.head); rest > 0 {
+		if rest > n {
+			rest = n
+		}
+		br.head = append(br.head, p[:rest]...)
+	}
+
+	return n, err
+}
+
 // SetContextLogger populates additional field of the provided logger and saves it into context.
 func SetContextLogger(log *zap.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -179,6 +218,44 @@ func GetDomainName(ctx context.Context) string {
 	return v
 }
 
+// RequireKeyword middleware checks that 'keyword' parameter is set and valid.
+func RequireKeyword(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyword := chi.URLParam(r, keywordNameParam)
+		// Router matches the raw path if it has escaped slashes, so the param is still escaped then
+		if r.URL.RawPath != "" {
+			var err error
+			if keyword, err = url.PathUnescape(keyword); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				JSON(w, map[string]string{"error": "keyword is invalid"})
+
+				return
+			}
+		}
+
+		keyword = strings.TrimSpace(keyword)
+		if err := validateKeyword(keyword); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), ctxKeyword, keyword)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetKeyword retrieves keyword value from context.
+func GetKeyword(ctx context.Context) string {
+	v, ok := ctx.Value(ctxKeyword).(string)
+	if !ok {
+		return ""
+	}
+
+	return v
+}
+
 // JSON marshals 'v' to JSON, automatically escaping HTML and setting the Content-Type as application/json.
 // It will call http.Error in case of failures.
 func JSON(w http.ResponseWriter, v interface{}) {
@@ -2,8 +2,13 @@ package v1
 
 import (
 	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/go-chi/chi"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,3 +28,51 @@ func TestGetRequestIDEmpty(t *testing.T) {
 	actual := GetRequestID(ctx)
 	assert.Equal(t, "", actual)
 }
+
+func TestRequireKeyword(t *testing.T) {
+	r := chi.NewRouter()
+	r.With(RequireKeyword).Get("/keywords/{"+keywordNameParam+"}", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(GetKeyword(req.Context())))
+	})
+
+	tests := []struct {
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"/keywords/leather", http.StatusOK, "leather"},
+		{"/keywords/leather%20bag", http.StatusOK, "leather bag"},
+		{"/keywords/a%2Fb", http.StatusOK, "a/b"},
+		{"/keywords/50%25%20off", http.StatusOK, "50% off"},
+		{"/keywords/%2541", http.StatusOK, "%41"},
+		{"/keywords/a%2Fb%2520c", http.StatusOK, "a/b%20c"},
+		{"/keywords/%20", http.StatusBadRequest, `{"error":"keyword is required"}`},
+		{"/keywords/" + strings.Repeat("a", 257), http.StatusBadRequest,
+			`{"error":"keyword must not be longer than 256 characters"}`},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, tt.path, nil)
+		assert.NoError(t, err)
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.expectedCode, w.Code, tt.path)
+		assert.Equal(t, tt.expectedBody, strings.TrimSpace(w.Body.String()), tt.path)
+	}
+}
+
+func TestBodyRecorder(t *testing.T) {
+	body := strings.Repeat("a", maxLoggedBodySize) + "b"
+	br := &bodyRecorder{ReadCloser: ioutil.NopCloser(strings.NewReader(body))}
+
+	read, err := ioutil.ReadAll(br)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(read))
+	assert.Equal(t, body[:maxLoggedBodySize], string(br.head))
+}
+
+func TestGetKeywordEmpty(t *testing.T) {
+	assert.Equal(t, "", GetKeyword(context.Background()))
+}
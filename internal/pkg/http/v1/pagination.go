@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+)
+
+const (
+	pageParam  = "page"
+	limitParam = "limit"
+
+	linkHeader = "Link"
+
+	// maxPageOffset is the largest number of rows skipped before a page, so that offsets fit
+	// into integers of any DB.
+	maxPageOffset = math.MaxInt32
+)
+
+// PaginationMeta describes a page of positions.
+type PaginationMeta struct {
+	Total   int  `json:"total"`
+	Pages   int  `json:"pages"`
+	Page    int  `json:"page,omitempty"`
+	PerPage int  `json:"per_page"`
+	HasMore bool `json:"has_more"`
+}
+
+// NewPaginationMeta returns description of the page, page is 0 for pages requested with a cursor.
+func NewPaginationMeta(total, page, perPage int, hasMore bool) *PaginationMeta {
+	return &PaginationMeta{
+		Total:   total,
+		Pages:   (total + perPage - 1) / perPage,
+		Page:    page,
+		PerPage: perPage,
+		HasMore: hasMore,
+	}
+}
+
+// positionsPerPageLimits returns default and maximum numbers of positions per page.
+func positionsPerPageLimits() (int, int) {
+	perPage, maxPerPage := defaultLimitPositionsPerPage, config.Config.PublicAPI.MaxPositionsPerPage
+	if config.Config.PublicAPI.PositionsPerPage > 0 {
+		perPage = config.Config.PublicAPI.PositionsPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	return perPage, maxPerPage
+}
+
+// DefaultLimit returns a number of positions per page returned if the client doesn't set the limit.
+func DefaultLimit() int {
+	perPage, _ := positionsPerPageLimits()
+
+	return perPage
+}
+
+// parseLimit returns a number of positions per page requested by the client.
+func parseLimit(query url.Values) (int, error) {
+	perPage, maxPerPage := positionsPerPageLimits()
+
+	raw := query.Get(limitParam)
+	if raw == "" {
+		return perPage, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxPerPage {
+		return 0, fmt.Errorf("'%s' must be an integer between 1 and %d", limitParam, maxPerPage)
+	}
+
+	return limit, nil
+}
+
+// parsePage returns a page number, the first page is returned for invalid values. Pages
+// whose offset doesn't fit into maxPageOffset are rejected.
+func parsePage(query url.Values, limit int) (int, error) {
+	page, err := strconv.Atoi(query.Get(pageParam))
+	if err != nil || page < 1 {
+		return 1, nil
+	}
+
+	if maxPage := maxPageOffset/limit + 1; page > maxPage {
+		return 0, fmt.Errorf("'%s' must not be greater than %d", pageParam, maxPage)
+	}
+
+	return page, nil
+}
+
+// setLinkHeader sets RFC 8288 links to the first, last, previous and next pages.
+func setLinkHeader(w http.ResponseWriter, req *http.Request, meta *PaginationMeta, nextCursor string) {
+	link := func(rel string, params map[string]string) string {
+		query := req.URL.Query()
+		query.Del(pageParam)
+		query.Del(cursorParam)
+		for k, v := range params {
+			query.Set(k, v)
+		}
+
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, req.URL.Path, query.Encode(), rel)
+	}
+	page := func(num int) map[string]string {
+		return map[string]string{pageParam: strconv.Itoa(num)}
+	}
+
+	lastPage := meta.Pages
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	links := []string{link("first", page(1))}
+	if meta.Page > 1 {
+		links = append(links, link("prev", page(meta.Page-1)))
+	}
+	if meta.HasMore {
+		// Pages requested with a cursor are continued with a cursor as well
+		if meta.Page == 0 {
+			links = append(links, link("next", map[string]string{cursorParam: nextCursor}))
+		} else {
+			links = append(links, link("next", page(meta.Page+1)))
+		}
+	}
+	links = append(links, link("last", page(lastPage)))
+
+	w.Header().Set(linkHeader, strings.Join(links, ", "))
+}
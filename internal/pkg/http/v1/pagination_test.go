@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPaginationMeta(t *testing.T) {
+	assert.Equal(t, &PaginationMeta{Total: 21, Pages: 3, Page: 1, PerPage: 10, HasMore: true},
+		NewPaginationMeta(21, 1, 10, true))
+	assert.Equal(t, &PaginationMeta{Total: 0, Pages: 0, Page: 1, PerPage: 10},
+		NewPaginationMeta(0, 1, 10, false))
+}
+
+func TestSetLinkHeaderCursor(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v1/positions/fidel.net?orderBy=cpc&cursor=abc", nil)
+
+	setLinkHeader(w, r, NewPaginationMeta(30, 0, 10, true), "def")
+
+	assert.Equal(t, `</v1/positions/fidel.net?orderBy=cpc&page=1>; rel="first", `+
+		`</v1/positions/fidel.net?cursor=def&orderBy=cpc>; rel="next", `+
+		`</v1/positions/fidel.net?orderBy=cpc&page=3>; rel="last"`, w.Header().Get(linkHeader))
+}
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		page     string
+		limit    int
+		expected int
+	}{
+		{"", 10, 1},
+		{"0", 10, 1},
+		{"abc", 10, 1},
+		{"3", 10, 3},
+		{"214748365", 10, 214748365},
+		{"2147483648", 1, 2147483648},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePage(url.Values{pageParam: {tt.page}}, tt.limit)
+		assert.NoError(t, err, tt.page)
+		assert.Equal(t, tt.expected, got, tt.page)
+	}
+
+	// Offset of the page would overflow
+	_, err := parsePage(url.Values{pageParam: {"214748366"}}, 10)
+	assert.EqualError(t, err, "'page' must not be greater than 214748365")
+	_, err = parsePage(url.Values{pageParam: {"9223372036854775807"}}, 100)
+	assert.EqualError(t, err, "'page' must not be greater than 21474837")
+}
@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"go.uber.org/zap"
+)
+
+const (
+	RetryAfterHeader         = "retry-after"
+	RateLimitLimitHeader     = "x-ratelimit-limit"
+	RateLimitRemainingHeader = "x-ratelimit-remaining"
+	RateLimitResetHeader     = "x-ratelimit-reset"
+)
+
+// authRoute is a name of the limit of requests authenticated with API keys.
+const authRoute = "auth"
+
+// RateLimit middleware limits requests of every client to the route with a token bucket,
+// requests over the limit get 429 Too Many Requests. Remaining requests are reported in
+// X-RateLimit-* headers. It's a no-op if the backend has no rate limiter.
+func RateLimit(b *backend.Backend, route string) func(next http.Handler) http.Handler {
+	return rateLimit(b, route, rateLimitClient)
+}
+
+// RateLimitAuthentication middleware limits requests of every IP address before API keys are
+// looked up, so that keys can't be guessed at the cost of a DB query each. It's a no-op if
+// authentication is disabled or the backend has no rate limiter.
+func RateLimitAuthentication(b *backend.Backend) func(next http.Handler) http.Handler {
+	limit := rateLimit(b, authRoute, func(r *http.Request, proxies []*net.IPNet) string {
+		return "ip:" + clientIP(r, proxies)
+	})
+
+	return func(next http.Handler) http.Handler {
+		if config.Config == nil || !config.Config.PublicAPI.Auth.Enabled {
+			return next
+		}
+
+		return limit(next)
+	}
+}
+
+func rateLimit(b *backend.Backend, route string,
+	client func(r *http.Request, proxies []*net.IPNet) string) func(next http.Handler) http.Handler {
+	route = strings.TrimPrefix(route, "/")
+
+	return func(next http.Handler) http.Handler {
+		if b.RateLimiter == nil {
+			return next
+		}
+		// Trusted proxies are validated when the config is loaded
+		proxies, _ := config.Config.PublicAPI.RateLimit.TrustedNetworks()
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result := b.RateLimiter.Allow(route, client(r, proxies))
+
+			h := w.Header()
+			h.Set(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+			h.Set(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+			h.Set(RateLimitResetHeader, ceilSeconds(result.Reset))
+
+			if !result.Allowed {
+				if log, err := GetContextLogger(r.Context()); err == nil {
+					log.Info("request is rate limited", zap.String("route", route))
+				}
+
+				h.Set(RetryAfterHeader, ceilSeconds(result.RetryAfter))
+				w.WriteHeader(http.StatusTooManyRequests)
+				JSON(w, map[string]string{"error": "rate limit exceeded"})
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitClient identifies the client by a verified API key or by IP address if there's no key.
+func rateLimitClient(r *http.Request, proxies []*net.IPNet) string {
+	if key := GetAPIKey(r.Context()); key != nil {
+		return "key:" + key.ID
+	}
+
+	return "ip:" + clientIP(r, proxies)
+}
+
+// clientIP returns an address of the client connected to the server, if it's a trusted proxy,
+// the last address added to X-Forwarded-For by an untrusted host is returned.
+func clientIP(r *http.Request, proxies []*net.IPNet) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !isTrustedProxy(ip, proxies) {
+		return ip
+	}
+
+	forwardedFor := r.Header.Get(XForwardedForHeader)
+	if forwardedFor == "" {
+		if realIP := strings.TrimSpace(r.Header.Get(XRealIPHeader)); realIP != "" {
+			return realIP
+		}
+
+		return ip
+	}
+
+	// Every proxy appends an address it got the request from, the leftmost ones are set by the client
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !isTrustedProxy(hop, proxies) {
+			return hop
+		}
+		ip = hop
+	}
+
+	return ip
+}
+
+func isTrustedProxy(addr string, proxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ceilSeconds formats the duration as a number of whole seconds rounded up.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
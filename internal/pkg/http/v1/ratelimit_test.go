@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitClient(t *testing.T) {
+	_, private, err := net.ParseCIDR("10.0.0.0/8")
+	assert.NoError(t, err)
+	proxies := []*net.IPNet{private}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		key          *db.APIKey
+		expected     string
+	}{
+		{"direct", "192.0.2.1:1234", "", "", nil, "ip:192.0.2.1"},
+		{"ipv6", "[2001:db8::1]:1234", "", "", nil, "ip:2001:db8::1"},
+		{"spoofed forwarded for", "192.0.2.1:1234", "198.51.100.1", "", nil, "ip:192.0.2.1"},
+		{"spoofed real ip", "192.0.2.1:1234", "", "198.51.100.1", nil, "ip:192.0.2.1"},
+		{"trusted proxy", "10.0.0.1:1234", "198.51.100.1, 10.0.0.2", "", nil, "ip:198.51.100.1"},
+		{"spoofed behind proxy", "10.0.0.1:1234", "203.0.113.1, 198.51.100.1", "", nil, "ip:198.51.100.1"},
+		{"only proxies", "10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "", nil, "ip:10.0.0.3"},
+		{"real ip of proxy", "10.0.0.1:1234", "", "198.51.100.1", nil, "ip:198.51.100.1"},
+		{"verified key", "192.0.2.1:1234", "", "", &db.APIKey{ID: "1"}, "key:1"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.forwardedFor != "" {
+			r.Header.Set(XForwardedForHeader, tt.forwardedFor)
+		}
+		if tt.realIP != "" {
+			r.Header.Set(XRealIPHeader, tt.realIP)
+		}
+		// Unverified keys don't identify clients
+		r.Header.Set(APIKeyHeader, "sb_1")
+		r = r.WithContext(context.WithValue(r.Context(), ctxPrincipal, &principal{apiKey: tt.key}))
+
+		assert.Equal(t, tt.expected, rateLimitClient(r, proxies), tt.name)
+	}
+}
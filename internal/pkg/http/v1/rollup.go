@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"go.uber.org/zap"
+)
+
+const (
+	rollupURL = "/rollup"
+
+	byParam    = "by"
+	depthParam = "depth"
+
+	maxRollupDepth = 5
+)
+
+// parseRollupsOpts parses and validates grouping and filter query parameters.
+func parseRollupsOpts(domain string, query url.Values) (*db.GetRollupsOpts, error) {
+	opts := &db.GetRollupsOpts{Domain: domain, By: query.Get(byParam), Depth: 1}
+
+	switch opts.By {
+	case "":
+		opts.By = db.RollupByHost
+	case db.RollupByHost, db.RollupByPath:
+	default:
+		return nil, fmt.Errorf("'%s' must be either '%s' or '%s'", byParam, db.RollupByHost, db.RollupByPath)
+	}
+
+	if raw := query.Get(depthParam); raw != "" {
+		depth, err := strconv.Atoi(raw)
+		if err != nil || depth < 1 || depth > maxRollupDepth {
+			return nil, fmt.Errorf("'%s' must be an integer between 1 and %d", depthParam, maxRollupDepth)
+		}
+		opts.Depth = depth
+	}
+
+	filter, err := parsePositionsFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	opts.Filter = filter
+
+	return opts, nil
+}
+
+func rollupHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log, err := GetContextLogger(req.Context())
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+		domain := GetDomainName(req.Context())
+
+		opts, err := parseRollupsOpts(domain, req.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		repo := newRepository(log, b)
+		rollups, err := repo.GetRollups(req.Context(), opts)
+		if err != nil {
+			log.Error("failed to get rollups", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		meterRows(req.Context(), len(rollups))
+		w.WriteHeader(http.StatusOK)
+		JSON(w, NewRollupResponse(opts, rollups))
+	}
+}
+
+func NewRollupResponse(opts *db.GetRollupsOpts, rollups []*db.Rollup) interface{} {
+	resp := struct {
+		Domain  string       `json:"domain"`
+		By      string       `json:"by"`
+		Depth   int          `json:"depth,omitempty"`
+		Rollups []*db.Rollup `json:"rollups"`
+	}{Domain: opts.Domain, By: opts.By, Rollups: rollups}
+	if opts.By == db.RollupByPath {
+		resp.Depth = opts.Depth
+	}
+
+	return resp
+}
@@ -0,0 +1,45 @@
+package v1
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRollupsOpts(t *testing.T) {
+	query, err := url.ParseQuery("by=path&depth=2&keywordPrefix=shoe")
+	assert.NoError(t, err)
+
+	got, err := parseRollupsOpts("fidel.net", query)
+	assert.NoError(t, err)
+	assert.Equal(t, &db.GetRollupsOpts{
+		Domain: "fidel.net",
+		By:     db.RollupByPath,
+		Depth:  2,
+		Filter: &db.PositionsFilter{KeywordPrefix: "shoe"},
+	}, got)
+
+	// Positions are grouped by host by default
+	got, err = parseRollupsOpts("fidel.net", url.Values{})
+	assert.NoError(t, err)
+	assert.Equal(t, db.RollupByHost, got.By)
+}
+
+func TestParseRollupsOptsErrors(t *testing.T) {
+	tests := map[string]string{
+		"by=keyword":         "'by' must be either 'host' or 'path'",
+		"by=path&depth=0":    "'depth' must be an integer between 1 and 5",
+		"by=path&depth=deep": "'depth' must be an integer between 1 and 5",
+		"minPosition=-1":     "'minPosition' must be a non-negative integer",
+	}
+
+	for rawQuery, expected := range tests {
+		query, err := url.ParseQuery(rawQuery)
+		assert.NoError(t, err)
+
+		_, err = parseRollupsOpts("fidel.net", query)
+		assert.EqualError(t, err, expected, rawQuery)
+	}
+}
@@ -1,11 +1,13 @@
 package v1
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
 	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
 	"github.com/go-chi/chi"
 	chimiddleware "github.com/go-chi/chi/middleware"
@@ -15,28 +17,107 @@ import (
 const (
 	summaryURL   = "/summary"
 	positionsURL = "/positions"
+	exportURL    = "/export"
 
+	// defaultLimitPositionsPerPage is used if it's not set in the config.
 	defaultLimitPositionsPerPage = 10
+	maxPositionsPerUpsert        = 10000
+	// maxUpsertBodySize limits a body of the upsert request, a position takes less than 2 KiB of JSON.
+	maxUpsertBodySize = maxPositionsPerUpsert * 2 << 10
 )
 
 // Routes initializes v1 handler.
 func Routes(log *zap.Logger, b *backend.Backend) http.Handler {
-	r := chi.NewRouter().
-		With(chimiddleware.Recoverer).
-		With(SetRequestID(log)).
-		With(RequestLogger(log)).
-		With(SetContextLogger(log)).
-		With(RequireDomainName)
+	r := chi.NewRouter()
+	r.Use(chimiddleware.Recoverer)
+	r.Use(SetRequestID(log))
+	r.Use(RequestLogger(log))
+	r.Use(SetContextLogger(log))
+	r.Use(RateLimitAuthentication(b))
+	r.Use(Authenticate(b))
 
-	// GET /v1/summary/<domain-name>
-	r.Get(fmt.Sprintf("%s/{%s}", summaryURL, domainNameParam), summaryHandler(b))
+	// Compression goes after RequestLogger, so that it logs sizes of compressed responses
+	if config.Config != nil && config.Config.PublicAPI.Compression.Enabled {
+		r.Use(Compress(config.Config.PublicAPI.Compression))
+	}
+
+	// Usage is aggregated in memory and stored periodically and on shutdown
+	var meter *db.UsageMeter
+	if config.Config != nil && config.Config.PublicAPI.Usage.Enabled {
+		meter = db.NewUsageMeter(log, b.DB, time.Duration(config.Config.PublicAPI.Usage.FlushInterval)*time.Second)
+		b.OnShutdown(meter.Close)
+	}
+
+	// GET /v1/usage?month=<YYYY-MM>
+	r.With(RateLimit(b, usageURL)).Get(usageURL, usageHandler(meter))
+
+	// Usage of the rest of routes is metered, so that usage can be seen with exceeded quotas
+	r.Group(func(r chi.Router) {
+		r.Use(MeterUsage(meter))
+
+		read := RequireScope(db.ScopeRead)
+
+		// GET /v1/compare?domains=<domain-name,...>&mode=<all|missing|weak|shared>&page=<page-num>&limit=<page-size>&<filter-params>
+		r.With(read, RateLimit(b, compareURL), ConditionalGet(b, compareURL)).Get(compareURL, compareHandler(b))
+
+		// GET /v1/keywords/<keyword>
+		r.With(RequireKeyword, read, RequireAllDomains, RateLimit(b, keywordsURL), ConditionalGet(b, keywordsURL)).Get(fmt.Sprintf("%s/{%s}", keywordsURL, keywordNameParam), keywordHandler(b))
+
+		// GET /v1/search?q=<query>&page=<page-num>&limit=<page-size>
+		r.With(read, RequireAllDomains, RateLimit(b, searchURL), ConditionalGet(b, searchURL)).Get(searchURL, searchHandler(b))
+
+		// Responses of GET routes except export are sent with ETag, Last-Modified and Cache-Control,
+		// export is streamed and can't be hashed beforehand. Last-Modified of competitors is the one
+		// of all domains, since they're compared with positions of other domains. Every route has its own rate limit,
+		// upserts share the limit of positions. Routes returning positions of any domain can't be
+		// used with API keys restricted to a list of domains.
+
+		// Routes of a single domain
+		r.Group(func(r chi.Router) {
+			r.Use(RequireDomainName, RequireDomainAccess)
+
+			// GET /v1/summary/<domain-name>?include=<section,...>
+			r.With(read, RateLimit(b, summaryURL), ConditionalGet(b, summaryURL)).Get(fmt.Sprintf("%s/{%s}", summaryURL, domainNameParam), summaryHandler(b))
 
-	// GET /v1/positions/<domain-name>?orderBy=<field>&page=<page-num>
-	r.Get(fmt.Sprintf("%s/{%s}", positionsURL, domainNameParam), positionsHandler(b))
+			// GET /v1/positions/<domain-name>?orderBy=<field>&page=<page-num>&cursor=<next-cursor>&limit=<page-size>&<filter-params>
+			r.With(read, RateLimit(b, positionsURL), ConditionalGet(b, positionsURL)).Get(fmt.Sprintf("%s/{%s}", positionsURL, domainNameParam), positionsHandler(b))
+
+			// GET /v1/positions/<domain-name>/export?format=<csv|ndjson>&orderBy=<field>&<filter-params>
+			r.With(RequireScope(db.ScopeExport), RateLimit(b, exportURL)).Get(fmt.Sprintf("%s/{%s}%s", positionsURL, domainNameParam, exportURL), exportPositionsHandler(b))
+
+			// GET /v1/positions/<domain-name>/rollup?by=<host|path>&depth=<path-segments>&<filter-params>
+			r.With(read, RateLimit(b, rollupURL), ConditionalGet(b, rollupURL)).Get(fmt.Sprintf("%s/{%s}%s", positionsURL, domainNameParam, rollupURL), rollupHandler(b))
+
+			// POST /v1/positions/<domain-name>
+			// PUT /v1/positions/<domain-name>
+			write := RequireScope(db.ScopeWrite)
+			r.With(write, RateLimit(b, positionsURL)).Post(fmt.Sprintf("%s/{%s}", positionsURL, domainNameParam), upsertPositionsHandler(b))
+			r.With(write, RateLimit(b, positionsURL)).Put(fmt.Sprintf("%s/{%s}", positionsURL, domainNameParam), upsertPositionsHandler(b))
+
+			// GET /v1/history/<domain-name>?keyword=<keyword>&url=<url>&from=<date>&to=<date>
+			r.With(read, RateLimit(b, historyURL), ConditionalGet(b, historyURL)).Get(fmt.Sprintf("%s/{%s}", historyURL, domainNameParam), historyHandler(b))
+
+			// GET /v1/changes/<domain-name>?from=<date>&to=<date>&page=<page-num>&limit=<page-size>
+			r.With(read, RateLimit(b, changesURL), ConditionalGet(b, changesURL)).Get(fmt.Sprintf("%s/{%s}", changesURL, domainNameParam), changesHandler(b))
+
+			// GET /v1/competitors/<domain-name>?page=<page-num>&limit=<page-size>
+			r.With(read, RateLimit(b, competitorsURL), ConditionalGetAllDomains(b, competitorsURL)).Get(fmt.Sprintf("%s/{%s}", competitorsURL, domainNameParam), competitorsHandler(b))
+		})
+	})
 
 	return r
 }
 
+// newRepository returns a storage of positions handlers work with, it's cached if the backend has a cache.
+func newRepository(log *zap.Logger, b *backend.Backend) db.Repository {
+	repo := db.NewPositionRepo(log, b.DB)
+	if b.Cache != nil {
+		return db.NewCachedRepo(repo, b.Cache)
+	}
+
+	return repo
+}
+
 func summaryHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 		log, err := GetContextLogger(req.Context())
@@ -47,17 +128,42 @@ func summaryHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Re
 		}
 		domain := GetDomainName(req.Context())
 
-		repo := db.NewPositionRepo(log, b.DB)
-		summary, err := repo.GetSummary(req.Context(), domain)
+		include, err := parseSummaryInclude(req.URL.Query().Get(includeParam))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		repo := newRepository(log, b)
+
+		// Basic summary stays as cheap as it used to be
+		if len(include) == 0 {
+			summary, err := repo.GetSummary(req.Context(), domain)
+			if err != nil {
+				log.Error("failed to get summary", zap.Error(err))
+				http.Error(w, "", http.StatusInternalServerError)
+
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+			JSON(w, NewSummaryResponse(domain, summary))
+
+			return
+		}
+
+		summary, err := repo.GetDomainSummary(req.Context(), domain)
 		if err != nil {
-			log.Error("failed to get summary", zap.Error(err))
+			log.Error("failed to get domain summary", zap.Error(err))
 			http.Error(w, "", http.StatusInternalServerError)
 
 			return
 		}
 
 		w.WriteHeader(http.StatusOK)
-		JSON(w, NewSummaryResponse(domain, summary))
+		JSON(w, NewExtendedSummaryResponse(summary, include))
 	}
 }
 
@@ -68,6 +174,69 @@ func NewSummaryResponse(domain string, positions int) interface{} {
 	}{Domain: domain, PositionsCount: positions}
 }
 
+// Sections of the extended summary.
+type (
+	distinctSummary struct {
+		Keywords int `json:"keywords"`
+		URLs     int `json:"urls"`
+	}
+	topSummary struct {
+		Top3   int `json:"top_3"`
+		Top10  int `json:"top_10"`
+		Top100 int `json:"top_100"`
+	}
+	positionSummary struct {
+		Average float64 `json:"average"`
+		Median  float64 `json:"median"`
+	}
+	volumeSummary struct {
+		Total        int64   `json:"total"`
+		TrafficValue float64 `json:"traffic_value"`
+	}
+	updatedSummary struct {
+		Min string `json:"min"`
+		Max string `json:"max"`
+	}
+	trafficSummary struct {
+		Estimated float64 `json:"estimated"`
+		Value     float64 `json:"value"`
+	}
+)
+
+func NewExtendedSummaryResponse(summary *db.DomainSummary, include map[string]struct{}) interface{} {
+	resp := struct {
+		Domain         string           `json:"domain"`
+		PositionsCount int              `json:"positions_count"`
+		Distinct       *distinctSummary `json:"distinct,omitempty"`
+		Top            *topSummary      `json:"top,omitempty"`
+		Position       *positionSummary `json:"position,omitempty"`
+		Volume         *volumeSummary   `json:"volume,omitempty"`
+		Updated        *updatedSummary  `json:"updated,omitempty"`
+		Traffic        *trafficSummary  `json:"traffic,omitempty"`
+	}{Domain: summary.Domain, PositionsCount: summary.PositionsCount}
+
+	if _, ok := include[summaryDistinct]; ok {
+		resp.Distinct = &distinctSummary{Keywords: summary.DistinctKeywords, URLs: summary.DistinctURLs}
+	}
+	if _, ok := include[summaryTop]; ok {
+		resp.Top = &topSummary{Top3: summary.Top3, Top10: summary.Top10, Top100: summary.Top100}
+	}
+	if _, ok := include[summaryPosition]; ok {
+		resp.Position = &positionSummary{Average: summary.AvgPosition, Median: summary.MedianPosition}
+	}
+	if _, ok := include[summaryVolume]; ok {
+		resp.Volume = &volumeSummary{Total: summary.TotalVolume, TrafficValue: summary.TrafficValue}
+	}
+	if _, ok := include[summaryUpdated]; ok {
+		resp.Updated = &updatedSummary{Min: summary.FirstUpdated, Max: summary.LastUpdated}
+	}
+	if _, ok := include[summaryTraffic]; ok {
+		resp.Traffic = &trafficSummary{Estimated: summary.EstimatedTraffic, Value: summary.EstimatedTrafficValue}
+	}
+
+	return resp
+}
+
 func positionsHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 		log, err := GetContextLogger(req.Context())
@@ -80,11 +249,21 @@ func positionsHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.
 
 		// TODO: put query params logic into middlewares
 
-		// Extract query params for page number and order by field
-		var pageNum int
-		rawPageNum := req.URL.Query().Get("page")
-		if pageNum, err = strconv.Atoi(rawPageNum); err != nil {
-			pageNum = 1
+		// Extract query params for page number, page size and order by field
+		limit, err := parseLimit(req.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		pageNum, err := parsePage(req.URL.Query(), limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
 		}
 
 		orderBy := req.URL.Query().Get("orderBy")
@@ -95,14 +274,29 @@ func positionsHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.
 			return
 		}
 
+		filter, err := parsePositionsFilter(req.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		// Cursor continues the previous page and takes precedence over page number
+		cursor, err := parseCursor(req.URL.Query(), orderBy)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+		if cursor != nil {
+			pageNum = 0
+		}
+
 		// Init repository and query domain's positions
-		repo := db.NewPositionRepo(log, b.DB)
-		positions, err := repo.GetPositions(
-			req.Context(),
-			domain,
-			orderBy,
-			defaultLimitPositionsPerPage,
-			defaultLimitPositionsPerPage*(pageNum-1))
+		repo := newRepository(log, b)
+		positions, err := repo.GetPositions(req.Context(), positionsPageOpts(domain, orderBy, filter, cursor, pageNum, limit))
 		if err != nil {
 			log.Error("failed to get positions", zap.Error(err))
 			http.Error(w, "", http.StatusInternalServerError)
@@ -110,15 +304,229 @@ func positionsHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.
 			return
 		}
 
+		total, err := repo.CountPositions(req.Context(), domain, filter)
+		if err != nil {
+			log.Error("failed to count positions", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		var nextCursor string
+		hasMore := len(positions) > limit
+		if hasMore {
+			positions = positions[:limit]
+			nextCursor = db.NewCursor(orderBy, positions[len(positions)-1]).Encode()
+		}
+
+		meta := NewPaginationMeta(total, pageNum, limit, hasMore)
+		setLinkHeader(w, req, meta, nextCursor)
+
 		// Write response
+		meterRows(req.Context(), len(positions))
+		w.WriteHeader(http.StatusOK)
+		JSON(w, NewPositionsResponse(domain, positions, nextCursor, meta))
+	}
+}
+
+// positionsPageOpts returns options selecting a page of positions, an extra position
+// tells whether there is a next page.
+func positionsPageOpts(domain, orderBy string, filter *db.PositionsFilter, cursor *db.Cursor, pageNum, limit int) *db.GetPositionsOpts {
+	return &db.GetPositionsOpts{
+		Domain:  domain,
+		OrderBy: orderBy,
+		Filter:  filter,
+		After:   cursor,
+		Limit:   limit + 1,
+		Offset:  limit * (pageNum - 1),
+	}
+}
+
+func NewPositionsResponse(domain string, positions []*db.Position, nextCursor string, meta *PaginationMeta) interface{} {
+	return struct {
+		Domain     string          `json:"domain"`
+		Positions  []*db.Position  `json:"positions"`
+		NextCursor string          `json:"next_cursor,omitempty"`
+		Meta       *PaginationMeta `json:"meta"`
+	}{Domain: domain, Positions: positions, NextCursor: nextCursor, Meta: meta}
+}
+
+func upsertPositionsHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log, err := GetContextLogger(req.Context())
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+		domain := GetDomainName(req.Context())
+
+		if req.ContentLength > maxUpsertBodySize {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			JSON(w, map[string]string{"error": fmt.Sprintf("request body must not be larger than %d bytes", maxUpsertBodySize)})
+
+			return
+		}
+
+		// Chunked bodies have no length, decoding fails once they exceed the limit
+		var positions []*db.Position
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxUpsertBodySize)).Decode(&positions); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": fmt.Sprintf("failed to decode positions: %s", err)})
+
+			return
+		}
+
+		if err := validatePositionsCount(len(positions)); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		// Nothing is written if at least one of the positions is invalid
+		if rowErrors := validatePositions(positions); len(rowErrors) > 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			JSON(w, NewValidationErrorResponse(rowErrors))
+
+			return
+		}
+
+		repo := newRepository(log, b)
+		result, err := repo.UpsertPositions(req.Context(), domain, positions)
+		if err != nil {
+			log.Error("failed to upsert positions", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
-		JSON(w, NewPositionsResponse(domain, positions))
+		JSON(w, NewUpsertPositionsResponse(domain, result))
 	}
 }
 
-func NewPositionsResponse(domain string, positions []*db.Position) interface{} {
+func NewUpsertPositionsResponse(domain string, result *db.UpsertResult) interface{} {
+	return struct {
+		Domain   string `json:"domain"`
+		Inserted int    `json:"inserted"`
+		Updated  int    `json:"updated"`
+	}{Domain: domain, Inserted: result.Inserted, Updated: result.Updated}
+}
+
+func NewValidationErrorResponse(rowErrors []*RowError) interface{} {
 	return struct {
-		Domain    string         `json:"domain"`
-		Positions []*db.Position `json:"positions"`
-	}{Domain: domain, Positions: positions}
+		Error  string      `json:"error"`
+		Errors []*RowError `json:"errors"`
+	}{Error: "positions validation failed", Errors: rowErrors}
+}
+
+func exportPositionsHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log, err := GetContextLogger(req.Context())
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+		domain := GetDomainName(req.Context())
+
+		format := req.URL.Query().Get("format")
+		if format == "" {
+			format = exportFormatCSV
+		}
+		if err := validateExportFormat(format); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		orderBy := req.URL.Query().Get("orderBy")
+		if err := validateOrderByField(orderBy); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		filter, err := parsePositionsFilter(req.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		// The response is started with the first position, so query errors
+		// can still be reported with a proper status code
+		var (
+			enc     positionsEncoder
+			written int
+		)
+		startResponse := func() (err error) {
+			enc, err = newPositionsEncoder(w, domain, format)
+
+			return err
+		}
+
+		meterExport(req.Context())
+
+		// Export outlasts the write timeout of the rest of responses
+		if timeout := config.Config.PublicAPI.ExportWriteTimeout; timeout > 0 {
+			if err := extendWriteDeadline(req.Context(), time.Duration(timeout)*time.Second); err != nil {
+				log.Warn("failed to extend write deadline of export", zap.Error(err))
+			}
+		}
+
+		repo := newRepository(log, b)
+		err = repo.StreamPositions(req.Context(), &db.GetPositionsOpts{
+			Domain:  domain,
+			OrderBy: orderBy,
+			Filter:  filter,
+		}, func(p *db.Position) error {
+			if enc == nil {
+				if err := startResponse(); err != nil {
+					return err
+				}
+			}
+			if err := enc.Encode(p); err != nil {
+				return err
+			}
+
+			written++
+			if written%exportFlushEvery == 0 {
+				return enc.Flush()
+			}
+
+			return nil
+		})
+		meterRows(req.Context(), written)
+		if err != nil {
+			if enc == nil {
+				log.Error("failed to export positions", zap.Error(err))
+				http.Error(w, "", http.StatusInternalServerError)
+
+				return
+			}
+
+			// Headers are already sent, the client will get a truncated response
+			log.Error("failed to stream positions", zap.Error(err), zap.Int("written", written))
+
+			return
+		}
+
+		// Domain has no positions at all
+		if enc == nil {
+			if err := startResponse(); err != nil {
+				log.Error("failed to start export", zap.Error(err))
+
+				return
+			}
+		}
+
+		if err := enc.Flush(); err != nil {
+			log.Error("failed to flush positions", zap.Error(err))
+		}
+	}
 }
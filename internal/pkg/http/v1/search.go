@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/backend"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"go.uber.org/zap"
+)
+
+const (
+	searchURL = "/search"
+
+	queryParam = "q"
+)
+
+func searchHandler(b *backend.Backend) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log, err := GetContextLogger(req.Context())
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		query := req.URL.Query().Get(queryParam)
+		if query == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": fmt.Sprintf("'%s' is required", queryParam)})
+
+			return
+		}
+		if len(query) > maxMatchLength {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{
+				"error": fmt.Sprintf("'%s' must not be longer than %d characters", queryParam, maxMatchLength),
+			})
+
+			return
+		}
+
+		limit, err := parseLimit(req.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		pageNum, err := parsePage(req.URL.Query(), limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		// An extra result tells whether there is a next page
+		repo := newRepository(log, b)
+		results, err := repo.SearchPositions(req.Context(), &db.SearchPositionsOpts{
+			Query:  query,
+			Limit:  limit + 1,
+			Offset: limit * (pageNum - 1),
+		})
+		switch {
+		case errors.Is(err, db.ErrEmptySearchQuery):
+			w.WriteHeader(http.StatusBadRequest)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		case errors.Is(err, db.ErrSearchUnavailable):
+			w.WriteHeader(http.StatusNotImplemented)
+			JSON(w, map[string]string{"error": err.Error()})
+
+			return
+		case err != nil:
+			log.Error("failed to search positions", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		total, err := repo.CountSearchResults(req.Context(), query)
+		if err != nil {
+			log.Error("failed to count search results", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		hasMore := len(results) > limit
+		if hasMore {
+			results = results[:limit]
+		}
+
+		meta := NewPaginationMeta(total, pageNum, limit, hasMore)
+		setLinkHeader(w, req, meta, "")
+
+		meterRows(req.Context(), len(results))
+		w.WriteHeader(http.StatusOK)
+		JSON(w, NewSearchResponse(query, results, meta))
+	}
+}
+
+func NewSearchResponse(query string, results []*db.SearchResult, meta *PaginationMeta) interface{} {
+	return struct {
+		Query     string             `json:"query"`
+		Positions []*db.SearchResult `json:"positions"`
+		Meta      *PaginationMeta    `json:"meta"`
+	}{Query: query, Positions: results, Meta: meta}
+}
@@ -0,0 +1,203 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	chimiddleware "github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+)
+
+const (
+	usageURL = "/usage"
+
+	monthParam  = "month"
+	monthLayout = "2006-01"
+
+	errQuotaExceeded = "quota_exceeded"
+)
+
+// usageRecord collects usage of a request, handlers add rows they return.
+type usageRecord struct {
+	rows int
+	// export is set by export, bytes of its responses are metered.
+	export bool
+}
+
+// MeterUsage middleware records usage of the request's API key with the meter and replies
+// with 403 Forbidden once a monthly quota of the key is exceeded. Rows and bytes aren't known
+// before the response, so the request exceeding a quota is served in full. Rows of 304 Not
+// Modified responses aren't counted as they aren't sent. Export bytes are counted before
+// compression, so they don't depend on encodings clients accept. It's a no-op without a meter.
+func MeterUsage(meter *db.UsageMeter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if meter == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := GetAPIKey(r.Context())
+			if key == nil {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+			log, err := GetContextLogger(r.Context())
+			if err != nil {
+				http.Error(w, "", http.StatusInternalServerError)
+
+				return
+			}
+
+			now := time.Now()
+
+			if quota := quotaOf(key.ID); quota != (config.Quota{}) {
+				from, to := db.MonthBounds(now)
+				used, err := meter.Total(r.Context(), key.ID, from, to)
+				if err != nil {
+					log.Error("failed to get usage", zap.Error(err))
+					http.Error(w, "", http.StatusInternalServerError)
+
+					return
+				}
+				if exceeded := exceededQuota(used, quota); exceeded != "" {
+					w.WriteHeader(http.StatusForbidden)
+					JSON(w, map[string]string{
+						"error":   errQuotaExceeded,
+						"message": fmt.Sprintf("monthly quota of %s is exceeded until %s", exceeded, to.Format(db.UsageDayLayout)),
+					})
+
+					return
+				}
+			}
+
+			record := &usageRecord{}
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), ctxUsage, record)))
+
+			// Requests over the rate limit aren't served, so they aren't billed
+			if ww.Status() == http.StatusTooManyRequests {
+				return
+			}
+
+			usage := db.Usage{Requests: 1, Rows: int64(record.rows)}
+			if ww.Status() == http.StatusNotModified {
+				usage.Rows = 0
+			}
+			if record.export {
+				usage.ExportBytes = int64(ww.BytesWritten())
+			}
+			meter.Record(key.ID, now, usage)
+		})
+	}
+}
+
+// meterRows adds rows returned by the handler to usage of the request.
+func meterRows(ctx context.Context, rows int) {
+	if record, ok := ctx.Value(ctxUsage).(*usageRecord); ok {
+		record.rows += rows
+	}
+}
+
+// meterExport makes bytes of the response metered as export.
+func meterExport(ctx context.Context) {
+	if record, ok := ctx.Value(ctxUsage).(*usageRecord); ok {
+		record.export = true
+	}
+}
+
+// quotaOf returns a monthly quota of the API key.
+func quotaOf(keyID string) config.Quota {
+	if quota, ok := config.Config.PublicAPI.Usage.Quotas[keyID]; ok {
+		return quota
+	}
+
+	return config.Config.PublicAPI.Usage.DefaultQuota
+}
+
+// exceededQuota returns a name of the first exhausted limit of the quota, empty string
+// means the usage is within the quota.
+func exceededQuota(used db.Usage, quota config.Quota) string {
+	switch {
+	case quota.Requests > 0 && used.Requests >= quota.Requests:
+		return fmt.Sprintf("%d requests", quota.Requests)
+	case quota.Rows > 0 && used.Rows >= quota.Rows:
+		return fmt.Sprintf("%d rows", quota.Rows)
+	case quota.ExportBytes > 0 && used.ExportBytes >= quota.ExportBytes:
+		return fmt.Sprintf("%d export bytes", quota.ExportBytes)
+	default:
+		return ""
+	}
+}
+
+func totalUsage(days []*db.DailyUsage) db.Usage {
+	var total db.Usage
+	for _, d := range days {
+		total.Add(d.Usage)
+	}
+
+	return total
+}
+
+func usageHandler(meter *db.UsageMeter) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		log, err := GetContextLogger(req.Context())
+		if err != nil {
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		key := GetAPIKey(req.Context())
+		if meter == nil || key == nil {
+			w.WriteHeader(http.StatusNotFound)
+			JSON(w, map[string]string{"error": "usage metering is disabled"})
+
+			return
+		}
+
+		month := time.Now()
+		if v := req.URL.Query().Get(monthParam); v != "" {
+			if month, err = time.Parse(monthLayout, v); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				JSON(w, map[string]string{"error": fmt.Sprintf("'%s' must be in YYYY-MM format", monthParam)})
+
+				return
+			}
+		}
+
+		from, to := db.MonthBounds(month)
+		days, err := meter.GetUsage(req.Context(), key.ID, from, to)
+		if err != nil {
+			log.Error("failed to get usage", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		JSON(w, NewUsageResponse(key.ID, from.Format(monthLayout), quotaOf(key.ID), days))
+	}
+}
+
+// NewUsageResponse returns usage of the key during the month, zero limits of the quota mean
+// no limit.
+func NewUsageResponse(keyID, month string, quota config.Quota, days []*db.DailyUsage) interface{} {
+	return struct {
+		APIKeyID string           `json:"api_key_id"`
+		Month    string           `json:"month"`
+		Usage    db.Usage         `json:"usage"`
+		Quota    db.Usage         `json:"quota"`
+		Days     []*db.DailyUsage `json:"days"`
+	}{
+		APIKeyID: keyID,
+		Month:    month,
+		Usage:    totalUsage(days),
+		Quota:    db.Usage{Requests: quota.Requests, Rows: quota.Rows, ExportBytes: quota.ExportBytes},
+		Days:     days,
+	}
+}
@@ -0,0 +1,28 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/config"
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExceededQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		used     db.Usage
+		quota    config.Quota
+		expected string
+	}{
+		{"no quota", db.Usage{Requests: 100, Rows: 100, ExportBytes: 100}, config.Quota{}, ""},
+		{"within quota", db.Usage{Requests: 9, Rows: 99}, config.Quota{Requests: 10, Rows: 100}, ""},
+		{"requests", db.Usage{Requests: 10}, config.Quota{Requests: 10, Rows: 100}, "10 requests"},
+		{"rows", db.Usage{Requests: 1, Rows: 150}, config.Quota{Requests: 10, Rows: 100}, "100 rows"},
+		{"export bytes", db.Usage{ExportBytes: 1024}, config.Quota{ExportBytes: 1024}, "1024 export bytes"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, exceededQuota(tt.used, tt.quota), tt.name)
+	}
+}
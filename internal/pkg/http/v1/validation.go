@@ -1,6 +1,19 @@
 package v1
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+)
+
+const (
+	cursorParam = "cursor"
+
+	descOrderPrefix = "-"
+)
 
 var validFieldsToOrderBy = map[string]struct{}{
 	"":         {},
@@ -11,12 +24,134 @@ var validFieldsToOrderBy = map[string]struct{}{
 	"url":      {},
 	"position": {},
 	"keyword":  {},
+	"traffic":  {},
 }
 
+// validateOrderByField checks a comma-separated list of fields to order by, a field
+// prefixed with '-' is sorted in descending order, e.g. "-volume,position".
 func validateOrderByField(orderBy string) error {
-	if _, ok := validFieldsToOrderBy[orderBy]; !ok {
-		return fmt.Errorf("positions can't be ordered by '%s' field", orderBy)
+	fields := strings.Split(orderBy, ",")
+	seen := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		name := strings.TrimPrefix(strings.TrimSpace(f), descOrderPrefix)
+		if _, ok := validFieldsToOrderBy[name]; !ok || (name == "" && orderBy != "") {
+			return fmt.Errorf("positions can't be ordered by '%s' field", f)
+		}
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("positions can't be ordered by '%s' field twice", name)
+		}
+		seen[name] = struct{}{}
+	}
+
+	return nil
+}
+
+// Optional sections of the domain summary.
+const (
+	includeParam = "include"
+
+	summaryDistinct = "distinct"
+	summaryTop      = "top"
+	summaryPosition = "position"
+	summaryVolume   = "volume"
+	summaryUpdated  = "updated"
+	summaryTraffic  = "traffic"
+	summaryAll      = "all"
+)
+
+var validSummarySections = map[string]struct{}{
+	summaryDistinct: {},
+	summaryTop:      {},
+	summaryPosition: {},
+	summaryVolume:   {},
+	summaryUpdated:  {},
+	summaryTraffic:  {},
+}
+
+// parseSummaryInclude parses a comma-separated list of summary sections, "all" includes every section.
+func parseSummaryInclude(include string) (map[string]struct{}, error) {
+	sections := make(map[string]struct{})
+	if include == "" {
+		return sections, nil
+	}
+
+	for _, section := range strings.Split(include, ",") {
+		section = strings.TrimSpace(section)
+		if section == summaryAll {
+			for s := range validSummarySections {
+				sections[s] = struct{}{}
+			}
+
+			return sections, nil
+		}
+		if _, ok := validSummarySections[section]; !ok {
+			return nil, fmt.Errorf("summary can't include '%s' section", section)
+		}
+		sections[section] = struct{}{}
+	}
+
+	return sections, nil
+}
+
+// parseCursor decodes the cursor query parameter, nil is returned if it's not set.
+func parseCursor(query url.Values, orderBy string) (*db.Cursor, error) {
+	token := query.Get(cursorParam)
+	if token == "" {
+		return nil, nil
+	}
+
+	return db.DecodeCursor(token, orderBy)
+}
+
+func validateExportFormat(format string) error {
+	if _, ok := exportContentTypes[format]; !ok {
+		return fmt.Errorf("positions can't be exported in '%s' format", format)
 	}
 
 	return nil
 }
+
+// RowError represents a validation error of a single position in a batch.
+type RowError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// validateKeyword checks a keyword given in the path.
+func validateKeyword(keyword string) error {
+	if keyword == "" {
+		return errors.New("keyword is required")
+	}
+	if len(keyword) > maxMatchLength {
+		return fmt.Errorf("keyword must not be longer than %d characters", maxMatchLength)
+	}
+
+	return nil
+}
+
+func validatePositionsCount(count int) error {
+	if count == 0 {
+		return errors.New("at least one position is required")
+	}
+	if count > maxPositionsPerUpsert {
+		return fmt.Errorf("no more than %d positions can be written at once", maxPositionsPerUpsert)
+	}
+
+	return nil
+}
+
+func validatePositions(positions []*db.Position) []*RowError {
+	var rowErrors []*RowError
+	for i, p := range positions {
+		if p == nil {
+			rowErrors = append(rowErrors, &RowError{Index: i, Error: "position is null"})
+
+			continue
+		}
+		if err := p.Validate(); err != nil {
+			rowErrors = append(rowErrors, &RowError{Index: i, Error: err.Error()})
+		}
+	}
+
+	return rowErrors
+}
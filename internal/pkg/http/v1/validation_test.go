@@ -0,0 +1,49 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOrderByField(t *testing.T) {
+	for _, orderBy := range []string{"", "volume", "-volume", "-volume,position", "cpc, -updated,keyword"} {
+		assert.NoError(t, validateOrderByField(orderBy), orderBy)
+	}
+
+	tests := map[string]string{
+		"wwwwat":         "positions can't be ordered by 'wwwwat' field",
+		"-":              "positions can't be ordered by '-' field",
+		"volume,":        "positions can't be ordered by '' field",
+		"--volume":       "positions can't be ordered by '--volume' field",
+		"volume,-volume": "positions can't be ordered by 'volume' field twice",
+	}
+	for orderBy, expected := range tests {
+		assert.EqualError(t, validateOrderByField(orderBy), expected, orderBy)
+	}
+}
+
+func TestParseSummaryInclude(t *testing.T) {
+	got, err := parseSummaryInclude("")
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	got, err = parseSummaryInclude("top, volume")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{summaryTop: {}, summaryVolume: {}}, got)
+
+	got, err = parseSummaryInclude("top,all")
+	assert.NoError(t, err)
+	assert.Len(t, got, len(validSummarySections))
+
+	// Sections of the request must not change the valid ones
+	delete(got, summaryTop)
+	assert.Contains(t, validSummarySections, summaryTop)
+
+	got, err = parseSummaryInclude("traffic")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{summaryTraffic: {}}, got)
+
+	_, err = parseSummaryInclude("top,clicks")
+	assert.EqualError(t, err, "summary can't include 'clicks' section")
+}
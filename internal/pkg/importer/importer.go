@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+)
+
+// DefaultBatchSize is a default number of positions written within a single transaction.
+const DefaultBatchSize = 1000
+
+// Options contains parameters of the import.
+type Options struct {
+	// BatchSize is a number of positions written within a single transaction.
+	BatchSize int
+
+	// DryRun rolls back every transaction, so nothing is written.
+	DryRun bool
+
+	// OnReject is called for every row that can't be loaded.
+	OnReject func(record *Record)
+}
+
+// Summary represents results of the import.
+type Summary struct {
+	Read     int
+	Inserted int
+	Updated  int
+	Rejected int
+}
+
+// Add sums up the given summary with the current one.
+func (s *Summary) Add(other *Summary) {
+	s.Read += other.Read
+	s.Inserted += other.Inserted
+	s.Updated += other.Updated
+	s.Rejected += other.Rejected
+}
+
+// Import reads all records and writes valid positions in batches.
+func Import(ctx context.Context, repo db.Repository, r *Reader, opts Options) (*Summary, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	summary := &Summary{}
+	batch := newBatch()
+
+	flush := func() error {
+		if batch.size == 0 {
+			return nil
+		}
+
+		result, err := repo.ImportPositions(ctx, batch.domains, opts.DryRun)
+		if err != nil {
+			return err
+		}
+		summary.Inserted += result.Inserted
+		summary.Updated += result.Updated
+		batch = newBatch()
+
+		return nil
+	}
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return summary, err
+		}
+
+		summary.Read++
+		if record.Err != nil {
+			summary.Rejected++
+			if opts.OnReject != nil {
+				opts.OnReject(record)
+			}
+
+			continue
+		}
+
+		batch.add(record)
+		if batch.size >= opts.BatchSize {
+			if err := flush(); err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return summary, err
+	}
+
+	return summary, nil
+}
+
+// batch groups positions by domain keeping the order of domains.
+type batch struct {
+	domains []*db.DomainPositions
+	index   map[string]*db.DomainPositions
+	size    int
+}
+
+func newBatch() *batch {
+	return &batch{index: make(map[string]*db.DomainPositions)}
+}
+
+func (b *batch) add(record *Record) {
+	dp, ok := b.index[record.Domain]
+	if !ok {
+		dp = &db.DomainPositions{Domain: record.Domain}
+		b.index[record.Domain] = dp
+		b.domains = append(b.domains, dp)
+	}
+	dp.Positions = append(dp.Positions, record.Position)
+	b.size++
+}
@@ -0,0 +1,159 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fields of a position that can be loaded from the input.
+const (
+	FieldDomain   = "domain"
+	FieldKeyword  = "keyword"
+	FieldPosition = "position"
+	FieldURL      = "url"
+	FieldVolume   = "volume"
+	FieldResults  = "results"
+	FieldCPC      = "cpc"
+	FieldUpdated  = "updated"
+)
+
+// requiredFields must be mapped to a column by every profile.
+var requiredFields = []string{FieldKeyword, FieldPosition, FieldURL}
+
+var knownFields = map[string]struct{}{
+	FieldDomain:   {},
+	FieldKeyword:  {},
+	FieldPosition: {},
+	FieldURL:      {},
+	FieldVolume:   {},
+	FieldResults:  {},
+	FieldCPC:      {},
+	FieldUpdated:  {},
+}
+
+// utf8BOM is a byte order mark that is prepended to the header by some spreadsheet editors.
+const utf8BOM = "\ufeff"
+
+// DefaultProfile is a name of the profile that matches columns of the 'positions' table.
+const DefaultProfile = "default"
+
+// Profile represents a mapping of position fields to columns of the input.
+type Profile struct {
+	Name    string
+	Columns map[string]string
+}
+
+// profiles contains built-in mappings for exports of common SEO tools.
+var profiles = map[string]*Profile{
+	DefaultProfile: {
+		Name: DefaultProfile,
+		Columns: map[string]string{
+			FieldDomain:   "domain",
+			FieldKeyword:  "keyword",
+			FieldPosition: "position",
+			FieldURL:      "url",
+			FieldVolume:   "volume",
+			FieldResults:  "results",
+			FieldCPC:      "cpc",
+			FieldUpdated:  "updated",
+		},
+	},
+	"semrush": {
+		Name: "semrush",
+		Columns: map[string]string{
+			FieldKeyword:  "Keyword",
+			FieldPosition: "Position",
+			FieldURL:      "URL",
+			FieldVolume:   "Search Volume",
+			FieldResults:  "Number of Results",
+			FieldCPC:      "CPC",
+			FieldUpdated:  "Timestamp",
+		},
+	},
+	"ahrefs": {
+		Name: "ahrefs",
+		Columns: map[string]string{
+			FieldKeyword:  "Keyword",
+			FieldPosition: "Current position",
+			FieldURL:      "Current URL",
+			FieldVolume:   "Volume",
+			FieldCPC:      "CPC",
+			FieldUpdated:  "Updated",
+		},
+	},
+}
+
+// Profiles returns names of the built-in profiles.
+func Profiles() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// GetProfile returns a copy of the built-in profile with the given name, the copy
+// can be safely customized with Override.
+func GetProfile(name string) (*Profile, error) {
+	p, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile '%s', available profiles: %s", name, strings.Join(Profiles(), ", "))
+	}
+
+	columns := make(map[string]string, len(p.Columns))
+	for field, column := range p.Columns {
+		columns[field] = column
+	}
+
+	return &Profile{Name: p.Name, Columns: columns}, nil
+}
+
+// Override remaps profile fields with the given 'field=column' pairs.
+func (p *Profile) Override(mappings []string) error {
+	for _, m := range mappings {
+		parts := strings.SplitN(m, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return fmt.Errorf("column mapping '%s' must be in 'field=column' format", m)
+		}
+
+		field := strings.ToLower(strings.TrimSpace(parts[0]))
+		if _, ok := knownFields[field]; !ok {
+			return fmt.Errorf("unknown field '%s' in column mapping '%s'", field, m)
+		}
+		p.Columns[field] = parts[1]
+	}
+
+	return nil
+}
+
+// resolve matches profile columns with the given header and returns indexes of
+// the mapped fields. Column names are compared case-insensitively.
+func (p *Profile) resolve(header []string) (map[string]int, error) {
+	indexes := make(map[string]int, len(header))
+	for i, column := range header {
+		indexes[normalizeColumn(column)] = i
+	}
+
+	fields := make(map[string]int, len(p.Columns))
+	for field, column := range p.Columns {
+		if i, ok := indexes[normalizeColumn(column)]; ok {
+			fields[field] = i
+		}
+	}
+
+	for _, field := range requiredFields {
+		if _, ok := fields[field]; !ok {
+			return nil, fmt.Errorf("column '%s' for '%s' field is missing in the input of '%s' profile",
+				p.Columns[field], field, p.Name)
+		}
+	}
+
+	return fields, nil
+}
+
+func normalizeColumn(column string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, utf8BOM)))
+}
@@ -0,0 +1,29 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProfileUnknown(t *testing.T) {
+	_, err := GetProfile("unknown")
+	assert.EqualError(t, err, "unknown profile 'unknown', available profiles: ahrefs, default, semrush")
+}
+
+func TestProfileOverride(t *testing.T) {
+	profile, err := GetProfile("semrush")
+	assert.NoError(t, err)
+
+	assert.NoError(t, profile.Override([]string{"Domain=Site", "updated=Date"}))
+	assert.Equal(t, "Site", profile.Columns[FieldDomain])
+	assert.Equal(t, "Date", profile.Columns[FieldUpdated])
+
+	// Built-in profile stays the same
+	assert.Equal(t, "Timestamp", profiles["semrush"].Columns[FieldUpdated])
+
+	assert.EqualError(t, profile.Override([]string{"rank"}),
+		"column mapping 'rank' must be in 'field=column' format")
+	assert.EqualError(t, profile.Override([]string{"rank=Rank"}),
+		"unknown field 'rank' in column mapping 'rank=Rank'")
+}
@@ -0,0 +1,337 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+)
+
+// Supported input formats.
+const (
+	FormatCSV    = "csv"
+	FormatTSV    = "tsv"
+	FormatNDJSON = "ndjson"
+)
+
+// maxNDJSONLineSize limits a size of a single NDJSON line.
+const maxNDJSONLineSize = 1024 * 1024
+
+// updatedLayouts contains layouts of dates that are accepted besides unix timestamps.
+var updatedLayouts = []string{
+	db.UpdatedLayout,
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"20060102",
+}
+
+// FormatFromPath detects input format by the file extension.
+func FormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".tsv", ".tab":
+		return FormatTSV, nil
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, nil
+	}
+
+	return "", fmt.Errorf("can't detect format of '%s' file, please specify it explicitly", path)
+}
+
+// Record represents a single row of the input. Rows that can't be loaded have Err set.
+// Line is a number of the row in the input, CSV and TSV headers are counted as well.
+type Record struct {
+	Line     int
+	Domain   string
+	Position *db.Position
+	Err      error
+}
+
+// rowReader reads rows of the input as values keyed by field name.
+type rowReader interface {
+	// next returns the next row and its line number or io.EOF at the end of the input.
+	next() (map[string]string, int, error)
+}
+
+// rowError represents an error of a single malformed row, the input can be read further.
+type rowError struct {
+	line int
+	err  error
+}
+
+func (e *rowError) Error() string {
+	return e.err.Error()
+}
+
+// Reader reads positions from CSV, TSV or NDJSON input.
+type Reader struct {
+	rows          rowReader
+	defaultDomain string
+	now           func() time.Time
+}
+
+// NewReader returns new instance of Reader. Positions without domain column get
+// the defaultDomain.
+func NewReader(r io.Reader, format string, profile *Profile, defaultDomain string) (*Reader, error) {
+	var (
+		rows rowReader
+		err  error
+	)
+
+	switch format {
+	case FormatCSV:
+		rows, err = newDelimitedReader(r, ',', profile)
+	case FormatTSV:
+		rows, err = newDelimitedReader(r, '\t', profile)
+	case FormatNDJSON:
+		rows = newNDJSONReader(r, profile)
+	default:
+		return nil, fmt.Errorf("unknown format '%s'", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		rows:          rows,
+		defaultDomain: defaultDomain,
+		now:           time.Now,
+	}, nil
+}
+
+// Read returns the next record or io.EOF at the end of the input.
+func (r *Reader) Read() (*Record, error) {
+	values, line, err := r.rows.next()
+	if err != nil {
+		var rowErr *rowError
+		if errors.As(err, &rowErr) {
+			return &Record{Line: rowErr.line, Err: rowErr.err}, nil
+		}
+
+		return nil, err
+	}
+
+	record := &Record{Line: line, Domain: values[FieldDomain]}
+	if record.Domain == "" {
+		record.Domain = r.defaultDomain
+	}
+	if record.Domain == "" {
+		record.Err = errors.New("domain is required")
+
+		return record, nil
+	}
+
+	record.Position, record.Err = r.parsePosition(values)
+	if record.Err == nil {
+		record.Err = record.Position.Validate()
+	}
+
+	return record, nil
+}
+
+func (r *Reader) parsePosition(values map[string]string) (*db.Position, error) {
+	p := &db.Position{
+		Keyword: values[FieldKeyword],
+		URL:     values[FieldURL],
+	}
+
+	ints := []struct {
+		field string
+		dst   *int
+	}{
+		{FieldPosition, &p.Position},
+		{FieldVolume, &p.Volume},
+		{FieldResults, &p.Results},
+	}
+	for _, i := range ints {
+		v, err := parseInt(values[i.field])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", i.field, err)
+		}
+		*i.dst = v
+	}
+
+	var err error
+	if p.CPC, err = parseFloat(values[FieldCPC]); err != nil {
+		return nil, fmt.Errorf("%s: %w", FieldCPC, err)
+	}
+
+	if p.Updated, err = r.parseUpdated(values[FieldUpdated]); err != nil {
+		return nil, fmt.Errorf("%s: %w", FieldUpdated, err)
+	}
+
+	return p, nil
+}
+
+// parseUpdated converts a date or a unix timestamp to the positions date layout.
+// Positions without a date are considered to be observed at the import time.
+func (r *Reader) parseUpdated(value string) (string, error) {
+	if value == "" {
+		return r.now().UTC().Format(db.UpdatedLayout), nil
+	}
+
+	for _, layout := range updatedLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Format(db.UpdatedLayout), nil
+		}
+	}
+
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(ts, 0).UTC().Format(db.UpdatedLayout), nil
+	}
+
+	return "", fmt.Errorf("can't parse '%s' date", value)
+}
+
+func parseInt(value string) (int, error) {
+	value = strings.ReplaceAll(value, ",", "")
+	if value == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		// Some tools export integers as floats, e.g. "10.0"
+		f, ferr := strconv.ParseFloat(value, 64)
+		if ferr != nil || f != float64(int(f)) {
+			return 0, fmt.Errorf("'%s' is not an integer", value)
+		}
+		v = int(f)
+	}
+
+	return v, nil
+}
+
+func parseFloat(value string) (float64, error) {
+	value = strings.TrimPrefix(strings.ReplaceAll(value, ",", ""), "$")
+	if value == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a number", value)
+	}
+
+	return v, nil
+}
+
+// delimitedReader reads CSV and TSV inputs with a header row.
+type delimitedReader struct {
+	r      *csv.Reader
+	fields map[string]int
+	row    int
+}
+
+func newDelimitedReader(r io.Reader, delimiter rune, profile *Profile) (*delimitedReader, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = delimiter
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("input is empty")
+		}
+
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	fields, err := profile.resolve(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &delimitedReader{r: cr, fields: fields, row: 1}, nil
+}
+
+func (dr *delimitedReader) next() (map[string]string, int, error) {
+	row, err := dr.r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, err
+		}
+		dr.row++
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, 0, &rowError{line: dr.row, err: parseErr.Err}
+		}
+
+		return nil, 0, err
+	}
+	dr.row++
+
+	values := make(map[string]string, len(dr.fields))
+	for field, i := range dr.fields {
+		if i < len(row) {
+			values[field] = strings.TrimSpace(row[i])
+		}
+	}
+
+	return values, dr.row, nil
+}
+
+// ndjsonReader reads inputs with a JSON object per line.
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	profile *Profile
+	line    int
+}
+
+func newNDJSONReader(r io.Reader, profile *Profile) *ndjsonReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxNDJSONLineSize)
+
+	return &ndjsonReader{scanner: scanner, profile: profile}
+}
+
+func (nr *ndjsonReader) next() (map[string]string, int, error) {
+	for nr.scanner.Scan() {
+		nr.line++
+
+		data := bytes.TrimSpace(nr.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var object map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&object); err != nil {
+			return nil, 0, &rowError{line: nr.line, err: fmt.Errorf("invalid JSON: %w", err)}
+		}
+
+		keys := make(map[string]interface{}, len(object))
+		for k, v := range object {
+			keys[normalizeColumn(k)] = v
+		}
+
+		values := make(map[string]string, len(nr.profile.Columns))
+		for field, column := range nr.profile.Columns {
+			if v, ok := keys[normalizeColumn(column)]; ok && v != nil {
+				values[field] = strings.TrimSpace(fmt.Sprint(v))
+			}
+		}
+
+		return values, nr.line, nil
+	}
+
+	if err := nr.scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return nil, 0, io.EOF
+}
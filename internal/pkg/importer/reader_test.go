@@ -0,0 +1,142 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dstdfx/solid-broccoli/internal/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(t *testing.T, r *Reader) []*Record {
+	var records []*Record
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if !assert.NoError(t, err) {
+			return records
+		}
+		records = append(records, record)
+	}
+}
+
+func TestReaderCSVDefaultProfile(t *testing.T) {
+	input := `keyword,position,domain,url,volume,results,cpc,updated
+test1,1,ulmart.ru,http://ulmart.ru/test1,43,40000,3.22,2017-05-20
+test2,-,ulmart.ru,http://ulmart.ru/test2,55,40000,1.22,2017-05-20
+test3,3,,http://ulmart.ru/test3,76,40000,2.22,1495248847
+`
+	profile, err := GetProfile(DefaultProfile)
+	assert.NoError(t, err)
+
+	r, err := NewReader(strings.NewReader(input), FormatCSV, profile, "")
+	assert.NoError(t, err)
+
+	records := readAll(t, r)
+	assert.Len(t, records, 3)
+
+	assert.NoError(t, records[0].Err)
+	assert.Equal(t, 2, records[0].Line)
+	assert.Equal(t, "ulmart.ru", records[0].Domain)
+	assert.Equal(t, &db.Position{
+		URL:      "http://ulmart.ru/test1",
+		Position: 1,
+		Keyword:  "test1",
+		Volume:   43,
+		Results:  40000,
+		CPC:      3.22,
+		Updated:  "2017-05-20",
+	}, records[0].Position)
+
+	assert.EqualError(t, records[1].Err, "position: '-' is not an integer")
+	assert.Equal(t, 3, records[1].Line)
+
+	assert.EqualError(t, records[2].Err, "domain is required")
+	assert.Equal(t, 4, records[2].Line)
+}
+
+func TestReaderTSVSemrushProfile(t *testing.T) {
+	input := "Keyword\tPosition\tSearch Volume\tCPC\tURL\tNumber of Results\tTimestamp\n" +
+		"leather\t20\t4,670,000\t14.6\thttps://fidel.net/Dyaus\t3100000000\t1495411200\n"
+
+	profile, err := GetProfile("semrush")
+	assert.NoError(t, err)
+
+	r, err := NewReader(strings.NewReader(input), FormatTSV, profile, "fidel.net")
+	assert.NoError(t, err)
+
+	records := readAll(t, r)
+	assert.Len(t, records, 1)
+	assert.NoError(t, records[0].Err)
+	assert.Equal(t, "fidel.net", records[0].Domain)
+	assert.Equal(t, &db.Position{
+		URL:      "https://fidel.net/Dyaus",
+		Position: 20,
+		Keyword:  "leather",
+		Volume:   4670000,
+		Results:  3100000000,
+		CPC:      14.6,
+		Updated:  "2017-05-22",
+	}, records[0].Position)
+}
+
+func TestReaderCSVMissingColumn(t *testing.T) {
+	profile, err := GetProfile("ahrefs")
+	assert.NoError(t, err)
+
+	_, err = NewReader(strings.NewReader("Keyword,Volume\nleather,10\n"), FormatCSV, profile, "fidel.net")
+	assert.EqualError(t, err, "column 'Current position' for 'position' field is missing in the input of 'ahrefs' profile")
+}
+
+func TestReaderNDJSON(t *testing.T) {
+	input := `{"keyword": "air", "rank": 41, "url": "https://fidel.net/air", "volume": 3390000, "cpc": 1.24}
+
+{"keyword": "air", "rank": 41,
+{"keyword": "", "rank": 1, "url": "https://fidel.net/"}
+`
+	profile, err := GetProfile(DefaultProfile)
+	assert.NoError(t, err)
+	assert.NoError(t, profile.Override([]string{"position=Rank"}))
+
+	r, err := NewReader(strings.NewReader(input), FormatNDJSON, profile, "fidel.net")
+	assert.NoError(t, err)
+	r.now = func() time.Time { return time.Date(2017, 5, 23, 10, 0, 0, 0, time.UTC) }
+
+	records := readAll(t, r)
+	assert.Len(t, records, 3)
+
+	assert.NoError(t, records[0].Err)
+	assert.Equal(t, 1, records[0].Line)
+	assert.Equal(t, &db.Position{
+		URL:      "https://fidel.net/air",
+		Position: 41,
+		Keyword:  "air",
+		Volume:   3390000,
+		CPC:      1.24,
+		Updated:  "2017-05-23",
+	}, records[0].Position)
+
+	assert.Error(t, records[1].Err)
+	assert.Equal(t, 3, records[1].Line)
+
+	assert.EqualError(t, records[2].Err, "keyword is required")
+	assert.Equal(t, 4, records[2].Line)
+}
+
+func TestFormatFromPath(t *testing.T) {
+	format, err := FormatFromPath("/tmp/positions.TSV")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatTSV, format)
+
+	format, err = FormatFromPath("positions.jsonl")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatNDJSON, format)
+
+	_, err = FormatFromPath("positions.xlsx")
+	assert.Error(t, err)
+}
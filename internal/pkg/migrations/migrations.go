@@ -0,0 +1,230 @@
+package migrations
+
+// Migration represents a versioned change of the DB schema.
+type Migration struct {
+	Version     int
+	Description string
+
+	up   statements
+	down statements
+
+	// sqliteOption is a compile option SQLite must be built with to apply the migration,
+	// otherwise it's recorded as applied without changes.
+	sqliteOption string
+}
+
+// statements contains SQL of a migration step for every supported DB, SQLite statements
+// are used by both sqlite and memory drivers. Empty statements are skipped.
+type statements struct {
+	sqlite   string
+	postgres string
+}
+
+// all contains migrations ordered by version. A released migration must never be changed,
+// the schema is changed by adding a new one. Tables are created only if they don't exist,
+// so databases created before migrations were introduced are adopted as is.
+var all = []*Migration{
+	{
+		Version:     1,
+		Description: "create positions table",
+		up: statements{
+			sqlite: `CREATE TABLE IF NOT EXISTS positions (
+					keyword text,
+					position integer,
+					domain text,
+					url text,
+					volume integer,
+					results integer,
+					cpc float,
+					updated datetime,
+					primary key (domain, url, keyword)
+			)`,
+			postgres: `CREATE TABLE IF NOT EXISTS positions (
+					keyword text,
+					position integer,
+					domain text,
+					url text,
+					volume integer,
+					results bigint,
+					cpc double precision,
+					updated bigint,
+					primary key (domain, url, keyword)
+			)`,
+		},
+		down: statements{
+			sqlite:   `DROP TABLE positions`,
+			postgres: `DROP TABLE positions`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "create positions history table",
+		up: statements{
+			sqlite: `CREATE TABLE IF NOT EXISTS positions_history (
+					domain text,
+					url text,
+					keyword text,
+					position integer,
+					volume integer,
+					results integer,
+					cpc float,
+					updated integer,
+					primary key (domain, url, keyword, updated)
+			)`,
+			postgres: `CREATE TABLE IF NOT EXISTS positions_history (
+					domain text,
+					url text,
+					keyword text,
+					position integer,
+					volume integer,
+					results bigint,
+					cpc double precision,
+					updated bigint,
+					primary key (domain, url, keyword, updated)
+			)`,
+		},
+		down: statements{
+			sqlite:   `DROP TABLE positions_history`,
+			postgres: `DROP TABLE positions_history`,
+		},
+	},
+	{
+		// Keyword lookup and competitors search select positions of all domains by keyword.
+		Version:     3,
+		Description: "index positions by keyword",
+		up: statements{
+			sqlite:   `CREATE INDEX IF NOT EXISTS positions_keyword_idx ON positions (keyword)`,
+			postgres: `CREATE INDEX IF NOT EXISTS positions_keyword_idx ON positions (keyword)`,
+		},
+		down: statements{
+			sqlite:   `DROP INDEX positions_keyword_idx`,
+			postgres: `DROP INDEX positions_keyword_idx`,
+		},
+	},
+	{
+		// Keys are stored as SHA-256 hashes, scopes and domains are comma-separated lists.
+		Version:     4,
+		Description: "create api keys table",
+		up: statements{
+			sqlite: `CREATE TABLE IF NOT EXISTS api_keys (
+					id text primary key,
+					name text not null,
+					key_hash text not null unique,
+					scopes text not null,
+					domains text not null,
+					created integer not null,
+					revoked integer not null default 0
+			)`,
+			postgres: `CREATE TABLE IF NOT EXISTS api_keys (
+					id text primary key,
+					name text not null,
+					key_hash text not null unique,
+					scopes text not null,
+					domains text not null,
+					created bigint not null,
+					revoked bigint not null default 0
+			)`,
+		},
+		down: statements{
+			sqlite:   `DROP TABLE api_keys`,
+			postgres: `DROP TABLE api_keys`,
+		},
+	},
+	{
+		// Days are stored as 'YYYY-MM-DD', so that months are selected by ranges of days.
+		Version:     5,
+		Description: "create api key usage table",
+		up: statements{
+			sqlite: `CREATE TABLE IF NOT EXISTS api_key_usage (
+					api_key_id text,
+					day text,
+					requests integer not null,
+					rows_returned integer not null,
+					export_bytes integer not null,
+					primary key (api_key_id, day)
+			)`,
+			postgres: `CREATE TABLE IF NOT EXISTS api_key_usage (
+					api_key_id text,
+					day text,
+					requests bigint not null,
+					rows_returned bigint not null,
+					export_bytes bigint not null,
+					primary key (api_key_id, day)
+			)`,
+		},
+		down: statements{
+			sqlite:   `DROP TABLE api_key_usage`,
+			postgres: `DROP TABLE api_key_usage`,
+		},
+	},
+	{
+		// Every write bumps a version of the domains it touches and of the empty domain standing
+		// for all positions, modified is a unix time of the write.
+		Version:     6,
+		Description: "create dataset versions table",
+		up: statements{
+			sqlite: `CREATE TABLE IF NOT EXISTS dataset_versions (
+					domain text primary key,
+					version integer not null,
+					modified integer not null
+			)`,
+			postgres: `CREATE TABLE IF NOT EXISTS dataset_versions (
+					domain text primary key,
+					version bigint not null,
+					modified bigint not null
+			)`,
+		},
+		down: statements{
+			sqlite:   `DROP TABLE dataset_versions`,
+			postgres: `DROP TABLE dataset_versions`,
+		},
+	},
+	{
+		// PostgreSQL builds search documents on the fly. Upserts never change key columns,
+		// so only inserts and deletes have to be synchronized unless positions are edited by hand.
+		Version:     7,
+		Description: "create positions search index",
+		up: statements{
+			sqlite: `CREATE VIRTUAL TABLE IF NOT EXISTS positions_fts USING fts5(
+					keyword,
+					url,
+					domain UNINDEXED,
+					tokenize = 'unicode61'
+			);
+			CREATE TRIGGER IF NOT EXISTS positions_fts_insert AFTER INSERT ON positions BEGIN
+				INSERT INTO positions_fts (keyword, url, domain) VALUES (new.keyword, new.url, new.domain);
+			END;
+			CREATE TRIGGER IF NOT EXISTS positions_fts_delete AFTER DELETE ON positions BEGIN
+				DELETE FROM positions_fts WHERE domain = old.domain AND url = old.url AND keyword = old.keyword;
+			END;
+			CREATE TRIGGER IF NOT EXISTS positions_fts_update AFTER UPDATE OF keyword, url, domain ON positions BEGIN
+				DELETE FROM positions_fts WHERE domain = old.domain AND url = old.url AND keyword = old.keyword;
+				INSERT INTO positions_fts (keyword, url, domain) VALUES (new.keyword, new.url, new.domain);
+			END;
+			INSERT INTO positions_fts (keyword, url, domain)
+				SELECT keyword, url, domain FROM positions
+				WHERE NOT EXISTS (SELECT 1 FROM positions_fts)`,
+		},
+		down: statements{
+			sqlite: `DROP TRIGGER IF EXISTS positions_fts_insert;
+			DROP TRIGGER IF EXISTS positions_fts_delete;
+			DROP TRIGGER IF EXISTS positions_fts_update;
+			DROP TABLE IF EXISTS positions_fts`,
+		},
+		sqliteOption: "ENABLE_FTS5",
+	},
+}
+
+// Latest returns a version of the schema the binary works with.
+func Latest() int {
+	return all[len(all)-1].Version
+}
+
+// sql returns statements for the DB.
+func (s statements) sql(postgres bool) string {
+	if postgres {
+		return s.postgres
+	}
+
+	return s.sqlite
+}
@@ -0,0 +1,272 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	createVersionTableQuery = `CREATE TABLE IF NOT EXISTS schema_migrations (
+				version integer primary key,
+				description text,
+				applied_at bigint
+		)`
+
+	selectAppliedQuery = `SELECT version, description, applied_at FROM schema_migrations ORDER BY version`
+	insertVersionQuery = `INSERT INTO schema_migrations (version, description, applied_at) VALUES ($1, $2, $3)`
+	deleteVersionQuery = `DELETE FROM schema_migrations WHERE version = $1`
+
+	sqliteOptionUsedQuery = `SELECT sqlite_compileoption_used($1)`
+)
+
+var (
+	// ErrSchemaOutdated is returned if the DB has pending migrations.
+	ErrSchemaOutdated = errors.New("DB schema is outdated")
+	// ErrSchemaTooNew is returned if the DB has migrations the binary doesn't know about.
+	ErrSchemaTooNew = errors.New("DB schema is newer than the binary")
+)
+
+// Status represents a state of a migration in the DB.
+type Status struct {
+	Version     int
+	Description string
+	// AppliedAt is nil for pending migrations.
+	AppliedAt *time.Time
+}
+
+// Migrator applies and reverts migrations, their versions are stored in 'schema_migrations' table.
+type Migrator struct {
+	conn     *sqlx.DB
+	postgres bool
+	log      *zap.Logger
+}
+
+// NewMigrator returns new instance of Migrator, migrations are run in the dialect of the
+// connection's driver.
+func NewMigrator(log *zap.Logger, conn *sqlx.DB) *Migrator {
+	return &Migrator{
+		conn:     conn,
+		postgres: conn.DriverName() == "postgres",
+		log:      log,
+	}
+}
+
+type appliedMigration struct {
+	Version     int    `db:"version"`
+	Description string `db:"description"`
+	AppliedAt   int64  `db:"applied_at"`
+}
+
+// applied returns migrations applied to the DB ordered by version.
+func (m *Migrator) applied(ctx context.Context) ([]*appliedMigration, error) {
+	if _, err := m.conn.ExecContext(ctx, createVersionTableQuery); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var applied []*appliedMigration
+	if err := m.conn.SelectContext(ctx, &applied, selectAppliedQuery); err != nil {
+		return nil, fmt.Errorf("failed to select applied migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+// Version returns a version of the latest applied migration, 0 means the schema is empty.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(applied) == 0 {
+		return 0, nil
+	}
+
+	return applied[len(applied)-1].Version, nil
+}
+
+// Status returns states of all migrations known to the binary or applied to the DB ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[int]*Status, len(all))
+	for _, migration := range all {
+		statuses[migration.Version] = &Status{
+			Version:     migration.Version,
+			Description: migration.Description,
+		}
+	}
+	for _, a := range applied {
+		appliedAt := time.Unix(a.AppliedAt, 0).UTC()
+		statuses[a.Version] = &Status{
+			Version:     a.Version,
+			Description: a.Description,
+			AppliedAt:   &appliedAt,
+		}
+	}
+
+	result := make([]*Status, 0, len(statuses))
+	for _, status := range statuses {
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result, nil
+}
+
+// Up applies pending migrations up to the target version, all of them if target is 0.
+// Every migration is applied within its own transaction, applied ones are returned.
+func (m *Migrator) Up(ctx context.Context, target int) ([]*Migration, error) {
+	if target < 0 || target > Latest() {
+		return nil, fmt.Errorf("target version must be between 1 and %d", Latest())
+	}
+	if target == 0 {
+		target = Latest()
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isApplied := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		isApplied[a.Version] = true
+	}
+
+	var done []*Migration
+	for _, migration := range all {
+		if migration.Version > target {
+			break
+		}
+		if isApplied[migration.Version] {
+			continue
+		}
+
+		m.log.Info("apply migration",
+			zap.Int("version", migration.Version),
+			zap.String("description", migration.Description))
+
+		up := migration.up
+		supported, err := m.supported(ctx, migration)
+		if err != nil {
+			return done, fmt.Errorf("failed to check migration %d: %w", migration.Version, err)
+		}
+		if !supported {
+			m.log.Warn("SQLite is built without the option the migration requires, it's applied without changes",
+				zap.Int("version", migration.Version),
+				zap.String("option", migration.sqliteOption))
+			up = statements{}
+		}
+
+		if err := m.run(ctx, up, insertVersionQuery,
+			migration.Version, migration.Description, time.Now().Unix()); err != nil {
+			return done, fmt.Errorf("failed to apply migration %d: %w", migration.Version, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down reverts the given number of the latest applied migrations, reverted ones are returned.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	if steps < 1 {
+		return nil, errors.New("number of steps must be positive")
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration, len(all))
+	for _, migration := range all {
+		byVersion[migration.Version] = migration
+	}
+
+	var done []*Migration
+	for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+		migration, ok := byVersion[applied[i].Version]
+		if !ok {
+			return done, fmt.Errorf("%w: migration %d is unknown", ErrSchemaTooNew, applied[i].Version)
+		}
+
+		m.log.Info("revert migration",
+			zap.Int("version", migration.Version),
+			zap.String("description", migration.Description))
+
+		if err := m.run(ctx, migration.down, deleteVersionQuery, migration.Version); err != nil {
+			return done, fmt.Errorf("failed to revert migration %d: %w", migration.Version, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Ensure checks that the DB schema matches the binary, pending migrations are applied
+// if autoMigrate is set and reported as ErrSchemaOutdated otherwise.
+func (m *Migrator) Ensure(ctx context.Context, autoMigrate bool) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case version > Latest():
+		return fmt.Errorf("%w: version %d, the binary supports up to %d", ErrSchemaTooNew, version, Latest())
+	case version == Latest():
+		return nil
+	case !autoMigrate:
+		return fmt.Errorf("%w: version %d, the binary requires %d, run 'solid-broccoli migrate up'",
+			ErrSchemaOutdated, version, Latest())
+	}
+
+	_, err = m.Up(ctx, 0)
+
+	return err
+}
+
+// supported reports whether the DB can apply the migration.
+func (m *Migrator) supported(ctx context.Context, migration *Migration) (bool, error) {
+	if m.postgres || migration.sqliteOption == "" {
+		return true, nil
+	}
+
+	var used bool
+	if err := m.conn.QueryRowxContext(ctx, sqliteOptionUsedQuery, migration.sqliteOption).Scan(&used); err != nil {
+		return false, err
+	}
+
+	return used, nil
+}
+
+// run executes statements of a migration step and updates schema_migrations in one transaction.
+func (m *Migrator) run(ctx context.Context, step statements, versionQuery string, args ...interface{}) error {
+	tx, err := m.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if query := step.sql(m.postgres); query != "" {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, versionQuery, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,154 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newTestMigrator(t *testing.T) *Migrator {
+	conn, err := sqlx.Connect("sqlite3", ":memory:")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return NewMigrator(zap.NewNop(), conn)
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator(t)
+
+	version, err := m.Version(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	done, err := m.Up(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, done, 1)
+
+	version, err = m.Version(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	done, err = m.Up(ctx, 0)
+	assert.NoError(t, err)
+	assert.Len(t, done, Latest()-1)
+
+	_, err = m.conn.Exec(`INSERT INTO positions_history (domain, url, keyword, updated) VALUES ('a.ru', 'a.ru/', 'a', 1)`)
+	assert.NoError(t, err)
+
+	done, err = m.Up(ctx, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, done)
+
+	done, err = m.Down(ctx, Latest())
+	assert.NoError(t, err)
+	assert.Len(t, done, Latest())
+	assert.Equal(t, Latest(), done[0].Version)
+
+	version, err = m.Version(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	_, err = m.conn.Exec(`SELECT COUNT(1) FROM positions`)
+	assert.Error(t, err)
+}
+
+func TestMigratorStatus(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator(t)
+
+	_, err := m.Up(ctx, 2)
+	assert.NoError(t, err)
+
+	statuses, err := m.Status(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, statuses, Latest())
+	for _, status := range statuses {
+		if status.Version <= 2 {
+			assert.NotNil(t, status.AppliedAt)
+		} else {
+			assert.Nil(t, status.AppliedAt)
+		}
+	}
+}
+
+func TestMigratorEnsure(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator(t)
+
+	err := m.Ensure(ctx, false)
+	assert.True(t, errors.Is(err, ErrSchemaOutdated))
+
+	assert.NoError(t, m.Ensure(ctx, true))
+	assert.NoError(t, m.Ensure(ctx, false))
+
+	_, err = m.conn.Exec(insertVersionQuery, Latest()+1, "from the future", 0)
+	assert.NoError(t, err)
+
+	err = m.Ensure(ctx, true)
+	assert.True(t, errors.Is(err, ErrSchemaTooNew))
+
+	_, err = m.Down(ctx, 1)
+	assert.True(t, errors.Is(err, ErrSchemaTooNew))
+}
+
+func TestMigratorSearchIndex(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator(t)
+
+	_, err := m.Up(ctx, 6)
+	assert.NoError(t, err)
+
+	_, err = m.conn.Exec(`INSERT INTO positions (domain, url, keyword) VALUES ('a.ru', 'a.ru/', 'a')`)
+	assert.NoError(t, err)
+
+	done, err := m.Up(ctx, 7)
+	assert.NoError(t, err)
+	assert.Len(t, done, 1)
+
+	var tables int
+	assert.NoError(t, m.conn.Get(&tables, `SELECT COUNT(1) FROM sqlite_master WHERE name = 'positions_fts'`))
+
+	supported, err := m.supported(ctx, done[0])
+	assert.NoError(t, err)
+	if !supported {
+		t.Log("SQLite is built without FTS5, run tests with 'sqlite_fts5' tag")
+		assert.Equal(t, 0, tables)
+
+		return
+	}
+	assert.Equal(t, 1, tables)
+
+	// Existing positions are indexed by the migration and new ones by triggers
+	_, err = m.conn.Exec(`INSERT INTO positions (domain, url, keyword) VALUES ('b.ru', 'b.ru/', 'b')`)
+	assert.NoError(t, err)
+
+	var indexed int
+	assert.NoError(t, m.conn.Get(&indexed, `SELECT COUNT(1) FROM positions_fts`))
+	assert.Equal(t, 2, indexed)
+
+	_, err = m.Down(ctx, 1)
+	assert.NoError(t, err)
+	assert.NoError(t, m.conn.Get(&tables, `SELECT COUNT(1) FROM sqlite_master WHERE name = 'positions_fts'`))
+	assert.Equal(t, 0, tables)
+}
+
+func TestMigratorInvalidArgs(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator(t)
+
+	_, err := m.Up(ctx, Latest()+1)
+	assert.Error(t, err)
+
+	_, err = m.Down(ctx, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets of idle clients are removed.
+const sweepInterval = time.Minute
+
+// Limit allows a client Burst requests at once and then Rate requests per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result represents a state of the client's bucket after a request.
+type Result struct {
+	Allowed bool
+	// Limit is a size of the bucket.
+	Limit int
+	// Remaining is a number of requests the client can make right now.
+	Remaining int
+	// RetryAfter is a time until the next request is allowed, it's zero for allowed requests.
+	RetryAfter time.Duration
+	// Reset is a time until the bucket is full.
+	Reset time.Duration
+}
+
+type bucketKey struct {
+	route  string
+	client string
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter keeps token buckets of clients per route. It's safe for concurrent use.
+type Limiter struct {
+	mu           sync.Mutex
+	defaultLimit Limit
+	limits       map[string]Limit
+	buckets      map[bucketKey]*bucket
+	throttled    map[string]uint64
+	lastSweep    time.Time
+
+	now func() time.Time
+}
+
+// New returns new instance of Limiter, routes without their own limit get the default one.
+func New(defaultLimit Limit, limits map[string]Limit) *Limiter {
+	return &Limiter{
+		defaultLimit: defaultLimit,
+		limits:       limits,
+		buckets:      make(map[bucketKey]*bucket),
+		throttled:    make(map[string]uint64),
+		lastSweep:    time.Now(),
+		now:          time.Now,
+	}
+}
+
+// Allow takes a token from the client's bucket of the route.
+func (l *Limiter) Allow(route, client string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	limit := l.limit(route)
+	key := bucketKey{route: route, client: client}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = refill(b, limit, now)
+	b.updated = now
+
+	result := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+		l.throttled[route]++
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+
+	return result
+}
+
+// Throttled returns numbers of rejected requests per route.
+func (l *Limiter) Throttled() map[string]uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	throttled := make(map[string]uint64, len(l.throttled))
+	for route, n := range l.throttled {
+		throttled[route] = n
+	}
+
+	return throttled
+}
+
+func (l *Limiter) limit(route string) Limit {
+	if limit, ok := l.limits[route]; ok {
+		return limit
+	}
+
+	return l.defaultLimit
+}
+
+// sweep removes full buckets, they're recreated full on the next request anyway.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		limit := l.limit(key.route)
+		if refill(b, limit, now) >= float64(limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// refill returns tokens of the bucket at the given time.
+func refill(b *bucket, limit Limit, now time.Time) float64 {
+	return math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
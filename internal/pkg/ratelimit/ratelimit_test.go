@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	l := New(Limit{Rate: 1, Burst: 2}, map[string]Limit{"search": {Rate: 0.5, Burst: 1}})
+	l.now = func() time.Time { return now }
+
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}, l.Allow("positions", "a"))
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}, l.Allow("positions", "a"))
+	assert.Equal(t, Result{Limit: 2, RetryAfter: time.Second, Reset: 2 * time.Second}, l.Allow("positions", "a"))
+
+	// Other clients and routes have their own buckets
+	assert.True(t, l.Allow("positions", "b").Allowed)
+	assert.True(t, l.Allow("summary", "a").Allowed)
+
+	// A token is added every second
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, Result{Limit: 2, RetryAfter: 500 * time.Millisecond, Reset: 1500 * time.Millisecond}, l.Allow("positions", "a"))
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, l.Allow("positions", "a").Allowed)
+
+	// Routes can have their own limits
+	assert.Equal(t, Result{Allowed: true, Limit: 1, Remaining: 0, Reset: 2 * time.Second}, l.Allow("search", "a"))
+	assert.Equal(t, Result{Limit: 1, RetryAfter: 2 * time.Second, Reset: 2 * time.Second}, l.Allow("search", "a"))
+
+	assert.Equal(t, map[string]uint64{"positions": 2, "search": 1}, l.Throttled())
+}
+
+func TestLimiterSweep(t *testing.T) {
+	now := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	l := New(Limit{Rate: 1, Burst: 10}, nil)
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+
+	l.Allow("positions", "a")
+	assert.Len(t, l.buckets, 1)
+
+	now = now.Add(sweepInterval)
+	l.Allow("positions", "b")
+	assert.Len(t, l.buckets, 1)
+}
@@ -11,15 +11,33 @@ const (
 	accTestsEnabled    = "ACC_TESTS"
 	accTestEnabledMsg  = "Acceptance tests suite is enabled"
 	accTestDisabledMsg = `Acceptance tests suite is disabled, you can enable it with ACC_TESTS=1`
+
+	// Tests use an in-memory database unless another one is set by these variables.
+	testDBDriver = "TEST_DB_DRIVER"
+	testDBDSN    = "TEST_DB_DSN"
 )
 
-// InitTestConfig initializes application configuration for tests.
+// InitTestConfig initializes application configuration for tests, every backend created
+// with it gets its own empty in-memory database by default.
 func InitTestConfig() {
+	driver := os.Getenv(testDBDriver)
+	if driver == "" {
+		driver = config.DBDriverMemory
+	}
+
 	config.Config = &config.AppConfig{
 		Log: config.LogConfig{
 			UseStdout: true,
 			Debug:     true,
 		},
+		DB: config.DBConfig{
+			Driver:      driver,
+			DSN:         os.Getenv(testDBDSN),
+			AutoMigrate: true,
+		},
+		PublicAPI: config.PublicAPIServerConfig{
+			MaxPositionsPerPage: 100,
+		},
 	}
 }
 
@@ -0,0 +1,72 @@
+package testutils
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	insertPositionQuery = `INSERT INTO positions (keyword, position, domain, url, volume, results, cpc, updated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	insertHistoryQuery = `INSERT INTO positions_history (keyword, position, domain, url, volume, results, cpc, updated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+)
+
+// FixturePosition represents a row of positions or positions_history table.
+type FixturePosition struct {
+	Domain   string
+	Keyword  string
+	URL      string
+	Position int
+	Volume   int
+	Results  int
+	CPC      float64
+	// Updated is a unix timestamp.
+	Updated int64
+}
+
+// Fixture contains rows loaded into the DB before a test.
+type Fixture struct {
+	Positions []FixturePosition
+	History   []FixturePosition
+}
+
+// DefaultFixture is loaded by PrepareDB, TestDomain has 3 positions in the top and
+// a history of one of them.
+var DefaultFixture = &Fixture{
+	Positions: []FixturePosition{
+		{"ulmart.ru", "test1", "http://ulmart.ru/test1", 1, 43, 40000, 3.22, 1495248847},
+		{"ulmart.ru", "test2", "http://ulmart.ru/test2", 2, 55, 40000, 1.22, 1495248847},
+		{"ulmart.ru", "test3", "http://ulmart.ru/test3", 3, 76, 40000, 2.22, 1495248847},
+		{"non-ulmart.ru", "test4", "http://nonulmart.ru/tests/2", 7, 32, 40000, 5.22, 1495248847},
+		{"non-ulmart.ru", "test4", "http://nonulmart.ru/tests", 11, 65, 40000, 5.22, 1495248847},
+		{"eldorado.ru", "test1", "http://eldorado.ru/test1", 4, 47, 40000, 3.22, 1495248847},
+		{"eldorado.ru", "test2", "http://eldorado.ru/test2", 8, 50, 40000, 1.22, 1495248847},
+		{"eldorado.ru", "test2", "http://eldorado.ru/test2/more", 12, 50, 40000, 1.22, 1495248847},
+	},
+	History: []FixturePosition{
+		{"ulmart.ru", "test1", "http://ulmart.ru/test1", 5, 40, 40000, 3.10, 1494979200},
+		{"ulmart.ru", "test1", "http://ulmart.ru/test1", 3, 41, 40000, 3.15, 1495065600},
+	},
+}
+
+// LoadFixture inserts rows of the fixture into tables created by CreateSchema.
+func LoadFixture(t *testing.T, conn *sqlx.DB, f *Fixture) {
+	tables := []struct {
+		query string
+		rows  []FixturePosition
+	}{
+		{insertPositionQuery, f.Positions},
+		{insertHistoryQuery, f.History},
+	}
+
+	for _, table := range tables {
+		for _, p := range table.rows {
+			_, err := conn.Exec(table.query, p.Keyword, p.Position, p.Domain, p.URL, p.Volume, p.Results, p.CPC, p.Updated)
+			assert.NoError(t, err)
+		}
+	}
+}
@@ -1,3 +1,3 @@
 #!/usr/bin/env bash
 echo "==> Building solid-broccoli binary..."
-go build -o solid-broccoli ./cmd/solid-broccoli/solidbroccoli.go
\ No newline at end of file
+go build -tags sqlite_fts5 -o solid-broccoli ./cmd/solid-broccoli/solidbroccoli.go
\ No newline at end of file
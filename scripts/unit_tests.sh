@@ -1,7 +1,7 @@
 #!/usr/bin/env bash
 
 echo "==> Running unit tests..."
-GO111MODULE=on go test -mod=vendor -timeout=5m -v --count=1 ./...
+GO111MODULE=on go test -mod=vendor -tags sqlite_fts5 -timeout=5m -v --count=1 ./...
 if [[ $? -ne 0 ]]; then
     echo ""
     echo "Unit tests failed."